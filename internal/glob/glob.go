@@ -0,0 +1,71 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glob
+
+import (
+	"path"
+	"strings"
+)
+
+// Match reports whether the slash separated name matches the pattern.
+// Each segment of the pattern uses path.Match syntax and a segment of ** matches zero or more segments.
+func Match(pattern string, name string) (bool, error) {
+	return match(split(pattern), split(name))
+}
+
+// Validate returns path.ErrBadPattern if the pattern is malformed
+func Validate(pattern string) error {
+	for _, segment := range split(pattern) {
+		if _, err := path.Match(segment, ""); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func split(s string) []string {
+	s = strings.Trim(path.Clean("/"+s), "/")
+	if s == "" {
+		return []string{}
+	}
+	return strings.Split(s, "/")
+}
+
+func match(pattern []string, name []string) (bool, error) {
+	for len(pattern) > 0 {
+		if pattern[0] == "**" {
+			// Collapse consecutive ** and try matching the rest of the pattern against every suffix of the name
+			for len(pattern) > 0 && pattern[0] == "**" {
+				pattern = pattern[1:]
+			}
+			for i := 0; i <= len(name); i++ {
+				ok, err := match(pattern, name[i:])
+				if err != nil || ok {
+					return ok, err
+				}
+			}
+			return false, nil
+		}
+		if len(name) == 0 {
+			return false, nil
+		}
+		ok, err := path.Match(pattern[0], name[0])
+		if err != nil || !ok {
+			return false, err
+		}
+		pattern, name = pattern[1:], name[1:]
+	}
+	return len(name) == 0, nil
+}
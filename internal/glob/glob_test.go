@@ -0,0 +1,62 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package glob_test
+
+import (
+	"testing"
+
+	"github.com/notedownorg/notedown/internal/glob"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+		wantErr bool
+	}{
+		{pattern: "a.md", name: "a.md", want: true},
+		{pattern: "a.md", name: "dir/a.md", want: false},
+		{pattern: "*.md", name: "a.md", want: true},
+		{pattern: "*.md", name: "dir/a.md", want: false},
+		{pattern: "dir/*", name: "dir/a.md", want: true},
+		{pattern: "dir/*", name: "dir/sub/a.md", want: false},
+		{pattern: "dir/**", name: "dir/sub/a.md", want: true},
+		{pattern: "dir/**", name: "dir", want: true},
+		{pattern: "**/*.md", name: "a.md", want: true},
+		{pattern: "**/*.md", name: "dir/sub/a.md", want: true},
+		{pattern: "**/archive/**", name: "projects/archive/old/a.md", want: true},
+		{pattern: "**/archive/**", name: "projects/archived/a.md", want: false},
+		{pattern: "a/**/**/b.md", name: "a/x/y/b.md", want: true},
+		{pattern: "dir/", name: "dir", want: true},
+		{pattern: "./dir/a.md", name: "dir/a.md", want: true},
+		{pattern: "[a-c].md", name: "b.md", want: true},
+		{pattern: "[a-c.md", name: "b.md", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern+" "+tt.name, func(t *testing.T) {
+			got, err := glob.Match(tt.pattern, tt.name)
+			if tt.wantErr {
+				assert.Error(t, err)
+				assert.Error(t, glob.Validate(tt.pattern))
+				return
+			}
+			assert.NoError(t, err)
+			assert.NoError(t, glob.Validate(tt.pattern))
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
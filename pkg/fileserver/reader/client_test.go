@@ -15,7 +15,9 @@
 package reader
 
 import (
+	"os"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -51,3 +53,53 @@ func TestDocuments_Client_Loadtest_1000(t *testing.T)  { loadtestDocuments_Clien
 func TestDocuments_Client_Loadtest_10000(t *testing.T) { loadtestDocuments_Client(10000, t) }
 
 // func TestDocuments_Client_Loadtest_50000(t *testing.T) { loadtestDocuments_Client(50000, t) }
+
+func TestDocuments_Client_Sync(t *testing.T) {
+	dir, err := copyTestData(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient(dir, "testclient")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The file watcher races with the sync to process the same files so may report errors for the deleted file
+	go func() {
+		for range client.Errors() {
+		}
+	}()
+	sub := make(chan Event)
+	client.Subscribe(sub)
+
+	// The cache is updated before sync returns, without waiting for the file watcher
+	writeFile(dir, "synced.md", "# Synced")
+	assert.NoError(t, client.Sync("synced.md"))
+	client.docMutex.RLock()
+	doc, ok := client.documents["synced.md"]
+	client.docMutex.RUnlock()
+	assert.True(t, ok)
+	assert.Equal(t, "# Synced", string(doc.Contents))
+	assert.Equal(t, Event{Op: Change, Key: "synced.md", Document: doc}, receive(t, sub, "synced.md"))
+
+	os.Remove(dir + "/synced.md")
+	assert.NoError(t, client.Sync("synced.md"))
+	client.docMutex.RLock()
+	_, ok = client.documents["synced.md"]
+	client.docMutex.RUnlock()
+	assert.False(t, ok)
+	assert.Equal(t, Delete, receive(t, sub, "synced.md").Op)
+}
+
+// Receive the next event for the key, the file watcher may also emit events but the synced one must come first
+func receive(t *testing.T, sub chan Event, key string) Event {
+	for {
+		select {
+		case ev := <-sub:
+			if ev.Key == key {
+				return ev
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("timed out waiting for event for %s", key)
+		}
+	}
+}
@@ -20,6 +20,7 @@ import (
 	"fmt"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -57,19 +58,13 @@ func (c *Client) processFile(path string, load bool) {
 			return
 		}
 
-		d, err := parseDocument()(string(contents))
+		d, err := newDocument(contents)
 		if err != nil {
 			slog.Error("failed to parse document", slog.String("file", path), slog.String("error", err.Error()))
 			c.errors <- fmt.Errorf("failed to parse document: %w", err)
 			return
 		}
 
-		hash := sha256.New()
-		hash.Write(contents)
-		checksum := fmt.Sprintf("%x", hash.Sum(nil))
-		d.Checksum = checksum
-		d.lastUpdated = time.Now().Unix()
-
 		slog.Debug("updating document in cache", slog.String("file", path), slog.String("relative", rel))
 
 		c.docMutex.Lock()
@@ -86,6 +81,51 @@ func (c *Client) processFile(path string, load bool) {
 	}()
 }
 
+// Sync reads the document and updates the cache before returning, rather than waiting for the file watcher to notice
+// the change. A change event is emitted for the document, or a delete event if it no longer exists.
+func (c *Client) Sync(relative string) error {
+	if !strings.HasSuffix(relative, ".md") {
+		return nil
+	}
+	slog.Debug("syncing file", slog.String("file", relative))
+
+	contents, err := os.ReadFile(c.absolute(relative))
+	if os.IsNotExist(err) {
+		c.docMutex.Lock()
+		delete(c.documents, relative)
+		c.docMutex.Unlock()
+		c.events <- Event{Op: Delete, Document: Document{}, Key: relative}
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+
+	d, err := newDocument(contents)
+	if err != nil {
+		return fmt.Errorf("failed to parse document: %w", err)
+	}
+
+	c.docMutex.Lock()
+	c.documents[relative] = d
+	c.docMutex.Unlock()
+	c.events <- Event{Op: Change, Document: d, Key: relative}
+	return nil
+}
+
+func newDocument(contents []byte) (Document, error) {
+	d, err := parseDocument()(string(contents))
+	if err != nil {
+		return Document{}, err
+	}
+
+	hash := sha256.New()
+	hash.Write(contents)
+	d.Checksum = fmt.Sprintf("%x", hash.Sum(nil))
+	d.lastUpdated = time.Now().Unix()
+	return d, nil
+}
+
 func (c *Client) isUpToDate(file string) bool {
 	info, err := os.Stat(file)
 	if err != nil {
@@ -0,0 +1,99 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+)
+
+// Passed to mutations in place of the checksum when the document has been modified since it was read, followed by the
+// document's latest checksum. Only anchored mutations can be applied to a stale document, they locate their content
+// and pass the latest checksum on to the mutation they wrap.
+const stalePrefix = "\x00stale:"
+
+func staleChecksum(latest string) string {
+	return stalePrefix + latest
+}
+
+func stale(checksum string) bool {
+	return strings.HasPrefix(checksum, stalePrefix)
+}
+
+// Whether the checksum can't be relied on to guard against stale writes
+func unguarded(checksum string) bool {
+	return checksum == "" || stale(checksum)
+}
+
+// ConflictError is returned when an anchored mutation cannot unambiguously locate the content it expects.
+type ConflictError struct {
+	Line    int
+	Content string
+	Reason  string
+}
+
+func (e *ConflictError) Error() string {
+	return fmt.Sprintf("conflict at line %d, expected '%s': %s", e.Line, e.Content, e.Reason)
+}
+
+// Guard a mutation with the content the caller expects to find at the given line. All line numbers are 1-indexed and
+// do not include frontmatter. If the content is no longer at the given line (e.g. lines have been added or removed
+// elsewhere in the document since it was read) the mutation is applied to the nearest line with identical content.
+// A ConflictError is returned if the content no longer exists or there are multiple equally near matches.
+func Anchored(number int, original string, mutation func(number int) LineMutation) LineMutation {
+	return AnchoredBlock(number, []string{original}, mutation)
+}
+
+// Guard a mutation with the block of lines the caller expects to find starting at the given line, in the same way as
+// Anchored. Every line of the block must be unchanged, so mutations that depend on the size of the block (e.g.
+// removing it) can't remove or skip over lines that have been added to or removed from it since it was read.
+func AnchoredBlock(number int, original []string, mutation func(number int) LineMutation) LineMutation {
+	return func(checksum string, lines []string) ([]string, error) {
+		located, err := locate(lines, number, original)
+		if err != nil {
+			return lines, err
+		}
+		if stale(checksum) {
+			checksum = strings.TrimPrefix(checksum, stalePrefix)
+		}
+		return mutation(located)(checksum, lines)
+	}
+}
+
+func locate(lines []string, number int, original []string) (int, error) {
+	matches := func(n int) bool {
+		return n >= 1 && n+len(original)-1 <= len(lines) && slices.Equal(lines[n-1:n-1+len(original)], original)
+	}
+	content := strings.Join(original, "\n")
+
+	// Exact position first, then search outwards for the nearest match
+	if matches(number) {
+		return number, nil
+	}
+	for distance := 1; number-distance >= 1 || number+distance <= len(lines); distance++ {
+		above, below := matches(number-distance), matches(number+distance)
+		if above && below {
+			return 0, &ConflictError{Line: number, Content: content, Reason: fmt.Sprintf("content found at both line %d and %d", number-distance, number+distance)}
+		}
+		if above {
+			return number - distance, nil
+		}
+		if below {
+			return number + distance, nil
+		}
+	}
+	return 0, &ConflictError{Line: number, Content: content, Reason: "content no longer exists"}
+}
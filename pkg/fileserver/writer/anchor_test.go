@@ -0,0 +1,185 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/notedownorg/notedown/pkg/fileserver/writer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAnchored(t *testing.T) {
+	update := func(number int) writer.LineMutation { return writer.UpdateLine(number, Text("updated")) }
+	remove := func(number int) writer.LineMutation { return writer.RemoveLine(number) }
+
+	tests := []struct {
+		name         string
+		number       int
+		original     string
+		mutation     func(int) writer.LineMutation
+		lines        []string
+		checksum     string
+		want         []string
+		wantConflict bool
+		wantError    bool
+	}{
+		{
+			name:     "Content is at the expected line",
+			number:   2,
+			original: "line 2",
+			mutation: update,
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "hash",
+			want:     []string{"line 1", "updated", "line 3"},
+		},
+		{
+			name:     "Content has moved down",
+			number:   2,
+			original: "line 2",
+			mutation: update,
+			lines:    []string{"new line", "line 1", "line 2", "line 3"},
+			checksum: writer.StaleChecksum,
+			want:     []string{"new line", "line 1", "updated", "line 3"},
+		},
+		{
+			name:     "Content has moved up",
+			number:   3,
+			original: "line 3",
+			mutation: remove,
+			lines:    []string{"line 2", "line 3"},
+			checksum: writer.StaleChecksum,
+			want:     []string{"line 2"},
+		},
+		{
+			name:     "Content is beyond the end of the document",
+			number:   10,
+			original: "line 3",
+			mutation: update,
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: writer.StaleChecksum,
+			want:     []string{"line 1", "line 2", "updated"},
+		},
+		{
+			name:     "Nearest match wins",
+			number:   2,
+			original: "dupe",
+			mutation: update,
+			lines:    []string{"line 1", "line 2", "dupe", "line 4", "line 5", "dupe"},
+			checksum: writer.StaleChecksum,
+			want:     []string{"line 1", "line 2", "updated", "line 4", "line 5", "dupe"},
+		},
+		{
+			name:         "Equally near matches conflict",
+			number:       2,
+			original:     "dupe",
+			mutation:     update,
+			lines:        []string{"dupe", "line 2", "dupe"},
+			checksum:     writer.StaleChecksum,
+			wantConflict: true,
+		},
+		{
+			name:         "Content no longer exists",
+			number:       2,
+			original:     "line 2",
+			mutation:     update,
+			lines:        []string{"line 1", "line 2 has been edited", "line 3"},
+			checksum:     writer.StaleChecksum,
+			wantConflict: true,
+		},
+		{
+			name:         "Empty document",
+			number:       1,
+			original:     "line 1",
+			mutation:     update,
+			lines:        []string{},
+			checksum:     writer.StaleChecksum,
+			wantConflict: true,
+		},
+		{
+			name:      "Missing checksum is still required",
+			number:    2,
+			original:  "line 2",
+			mutation:  update,
+			lines:     []string{"line 1", "line 2", "line 3"},
+			checksum:  "",
+			wantError: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := writer.Anchored(tt.number, tt.original, tt.mutation)(tt.checksum, tt.lines)
+			if tt.wantError {
+				assert.Error(t, err)
+				return
+			}
+			if tt.wantConflict {
+				var conflict *writer.ConflictError
+				assert.True(t, errors.As(err, &conflict), "expected a conflict error got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestAnchoredBlock(t *testing.T) {
+	remove := func(number int) writer.LineMutation { return writer.RemoveLines(number, number+1) }
+	block := []string{"- [ ] Task", "  - [ ] Subtask"}
+
+	tests := []struct {
+		name         string
+		lines        []string
+		want         []string
+		wantConflict bool
+	}{
+		{
+			name:  "Block is at the expected line",
+			lines: []string{"line 1", "- [ ] Task", "  - [ ] Subtask", "line 4"},
+			want:  []string{"line 1", "line 4"},
+		},
+		{
+			name:  "Block has moved",
+			lines: []string{"new line", "line 1", "- [ ] Task", "  - [ ] Subtask", "line 4"},
+			want:  []string{"new line", "line 1", "line 4"},
+		},
+		{
+			name:         "Line added inside the block",
+			lines:        []string{"line 1", "- [ ] Task", "  A new note", "  - [ ] Subtask", "line 4"},
+			wantConflict: true,
+		},
+		{
+			name:         "Line removed from the block",
+			lines:        []string{"line 1", "- [ ] Task", "line 4"},
+			wantConflict: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := writer.AnchoredBlock(2, block, remove)(writer.StaleChecksum, tt.lines)
+			if tt.wantConflict {
+				var conflict *writer.ConflictError
+				assert.True(t, errors.As(err, &conflict), "expected a conflict error got %v", err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,114 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Add a block of one or more lines to the content, the block's text is split on newlines.
+// All line numbers are 1-indexed and do not include frontmatter, the first line of the block will be at the given number
+// and any following lines are shifted down by the size of the block.
+func AddBlock(number int, obj fmt.Stringer) LineMutation {
+	return func(checksum string, lines []string) ([]string, error) {
+		block, err := splitBlock(obj.String())
+		if err != nil {
+			return lines, fmt.Errorf("invalid text adding block '%s': %w", obj, err)
+		}
+		if stale(checksum) {
+			return lines, fmt.Errorf("document has been modified since it was read")
+		}
+		if unguarded(checksum) && number != AT_END && number != AT_BEGINNING {
+			return lines, fmt.Errorf("hash must be provided when adding a block in the middle of a document")
+		}
+
+		// Clamp to the start and end of the document
+		index := number - 1
+		if number == AT_END || number > len(lines) {
+			index = len(lines)
+		}
+		if number == AT_BEGINNING || number <= 1 {
+			index = 0
+		}
+		return splice(lines, index, index, block), nil
+	}
+}
+
+// Replace the lines between start and end (inclusive) with a block of one or more lines.
+// All line numbers are 1-indexed and do not include frontmatter.
+func ReplaceLines(start int, end int, obj fmt.Stringer) LineMutation {
+	return func(checksum string, lines []string) ([]string, error) {
+		block, err := splitBlock(obj.String())
+		if err != nil {
+			return lines, fmt.Errorf("invalid text replacing lines '%s': %w", obj, err)
+		}
+		if unguarded(checksum) {
+			return lines, fmt.Errorf("hash must be provided when replacing lines to avoid stale writes")
+		}
+		if err := validateRange(start, end, len(lines)); err != nil {
+			return lines, err
+		}
+		return splice(lines, start-1, end, block), nil
+	}
+}
+
+// Remove the lines between start and end (inclusive) from the content.
+// All line numbers are 1-indexed and do not include frontmatter.
+func RemoveLines(start int, end int) LineMutation {
+	return func(checksum string, lines []string) ([]string, error) {
+		if unguarded(checksum) {
+			return lines, fmt.Errorf("hash must be provided when removing lines to avoid stale writes")
+		}
+		if err := validateRange(start, end, len(lines)); err != nil {
+			return lines, err
+		}
+		return splice(lines, start-1, end, nil), nil
+	}
+}
+
+// Replace lines[from:to] with block, always returning a new slice so the input is never modified.
+func splice(lines []string, from int, to int, block []string) []string {
+	res := make([]string, 0, len(lines)-(to-from)+len(block))
+	res = append(res, lines[:from]...)
+	res = append(res, block...)
+	return append(res, lines[to:]...)
+}
+
+func splitBlock(text string) ([]string, error) {
+	// Blocks are expected to be written as a series of lines so ignore a single trailing newline
+	text = strings.TrimSuffix(text, "\n")
+	block := strings.Split(text, "\n")
+	for i, line := range block {
+		block[i] = strings.TrimSuffix(line, "\r")
+		if strings.Contains(block[i], "\r") {
+			return nil, fmt.Errorf("text contains carriage return character")
+		}
+	}
+	return block, nil
+}
+
+func validateRange(start int, end int, length int) error {
+	if start == AT_END || start == AT_BEGINNING || end == AT_END || end == AT_BEGINNING {
+		return fmt.Errorf("must provide absolute line numbers for a range")
+	}
+	if start > end {
+		return fmt.Errorf("start of range %d is after the end %d", start, end)
+	}
+	if start <= 0 || end > length {
+		return fmt.Errorf("line range out of bounds")
+	}
+	return nil
+}
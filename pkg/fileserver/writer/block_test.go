@@ -0,0 +1,307 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer_test
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/notedownorg/notedown/pkg/fileserver/writer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlock_AddBlock(t *testing.T) {
+	tests := []struct {
+		name     string
+		number   int
+		block    fmt.Stringer
+		lines    []string
+		checksum string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "Add block at beginning",
+			number:   writer.AT_BEGINNING,
+			block:    Text("new 1\nnew 2"),
+			lines:    []string{"line 1", "line 2"},
+			checksum: "",
+			want:     []string{"new 1", "new 2", "line 1", "line 2"},
+		},
+		{
+			name:     "Add block at end",
+			number:   writer.AT_END,
+			block:    Text("new 1\nnew 2"),
+			lines:    []string{"line 1", "line 2"},
+			checksum: "",
+			want:     []string{"line 1", "line 2", "new 1", "new 2"},
+		},
+		{
+			name:     "Add block in the middle",
+			number:   2,
+			block:    Text("- [ ] Task\n  - [ ] Subtask"),
+			lines:    []string{"line 1", "line 2"},
+			checksum: "hash",
+			want:     []string{"line 1", "- [ ] Task", "  - [ ] Subtask", "line 2"},
+		},
+		{
+			name:     "Add block in the middle with empty checksum",
+			number:   2,
+			block:    Text("new 1\nnew 2"),
+			lines:    []string{"line 1", "line 2"},
+			checksum: "",
+			wantErr:  true,
+		},
+		{
+			name:     "Add block at > number of lines",
+			number:   999,
+			block:    Text("new 1\nnew 2"),
+			lines:    []string{"line 1", "line 2"},
+			checksum: "hash",
+			want:     []string{"line 1", "line 2", "new 1", "new 2"},
+		},
+		{
+			name:     "Add block with a single line",
+			number:   1,
+			block:    Text("new 1"),
+			lines:    []string{"line 1", "line 2"},
+			checksum: "hash",
+			want:     []string{"new 1", "line 1", "line 2"},
+		},
+		{
+			name:     "Add block with trailing newline",
+			number:   1,
+			block:    Text("## Heading\nParagraph\n"),
+			lines:    []string{"line 1"},
+			checksum: "hash",
+			want:     []string{"## Heading", "Paragraph", "line 1"},
+		},
+		{
+			name:     "Add block with blank lines",
+			number:   writer.AT_END,
+			block:    Text("## Heading\n\nParagraph"),
+			lines:    []string{"line 1"},
+			checksum: "hash",
+			want:     []string{"line 1", "## Heading", "", "Paragraph"},
+		},
+		{
+			name:     "Add block with windows newlines",
+			number:   writer.AT_END,
+			block:    Text("new 1\r\nnew 2"),
+			lines:    []string{"line 1"},
+			checksum: "hash",
+			want:     []string{"line 1", "new 1", "new 2"},
+		},
+		{
+			name:     "Add block with stray carriage return",
+			number:   writer.AT_END,
+			block:    Text("new\r1"),
+			lines:    []string{"line 1"},
+			checksum: "hash",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := writer.AddBlock(tt.number, tt.block)(tt.checksum, tt.lines)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBlock_ReplaceLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    int
+		end      int
+		block    fmt.Stringer
+		lines    []string
+		checksum string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "Replace a single line with a block",
+			start:    2,
+			end:      2,
+			block:    Text("new 1\nnew 2"),
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "hash",
+			want:     []string{"line 1", "new 1", "new 2", "line 3"},
+		},
+		{
+			name:     "Replace a range with a single line",
+			start:    1,
+			end:      2,
+			block:    Text("new 1"),
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "hash",
+			want:     []string{"new 1", "line 3"},
+		},
+		{
+			name:     "Replace the whole document",
+			start:    1,
+			end:      3,
+			block:    Text("new 1\nnew 2"),
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "hash",
+			want:     []string{"new 1", "new 2"},
+		},
+		{
+			name:     "Replace with empty checksum",
+			start:    1,
+			end:      2,
+			block:    Text("new 1"),
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "",
+			wantErr:  true,
+		},
+		{
+			name:     "Replace with start after end",
+			start:    3,
+			end:      2,
+			block:    Text("new 1"),
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "hash",
+			wantErr:  true,
+		},
+		{
+			name:     "Replace with end out of bounds",
+			start:    2,
+			end:      4,
+			block:    Text("new 1"),
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "hash",
+			wantErr:  true,
+		},
+		{
+			name:     "Replace with start out of bounds",
+			start:    -1,
+			end:      2,
+			block:    Text("new 1"),
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "hash",
+			wantErr:  true,
+		},
+		{
+			name:     "Replace at end",
+			start:    1,
+			end:      writer.AT_END,
+			block:    Text("new 1"),
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "hash",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := writer.ReplaceLines(tt.start, tt.end, tt.block)(tt.checksum, tt.lines)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestBlock_RemoveLines(t *testing.T) {
+	tests := []struct {
+		name     string
+		start    int
+		end      int
+		lines    []string
+		checksum string
+		want     []string
+		wantErr  bool
+	}{
+		{
+			name:     "Remove a single line",
+			start:    2,
+			end:      2,
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "hash",
+			want:     []string{"line 1", "line 3"},
+		},
+		{
+			name:     "Remove a range",
+			start:    2,
+			end:      3,
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "hash",
+			want:     []string{"line 1"},
+		},
+		{
+			name:     "Remove everything",
+			start:    1,
+			end:      3,
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "hash",
+			want:     []string{},
+		},
+		{
+			name:     "Remove with empty checksum",
+			start:    1,
+			end:      2,
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "",
+			wantErr:  true,
+		},
+		{
+			name:     "Remove with start after end",
+			start:    2,
+			end:      1,
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "hash",
+			wantErr:  true,
+		},
+		{
+			name:     "Remove out of bounds",
+			start:    3,
+			end:      4,
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "hash",
+			wantErr:  true,
+		},
+		{
+			name:     "Remove at beginning",
+			start:    writer.AT_BEGINNING,
+			end:      1,
+			lines:    []string{"line 1", "line 2", "line 3"},
+			checksum: "hash",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := writer.RemoveLines(tt.start, tt.end)(tt.checksum, tt.lines)
+			if tt.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+				assert.Equal(t, tt.want, got)
+			}
+		})
+	}
+}
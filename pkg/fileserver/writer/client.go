@@ -14,14 +14,73 @@
 
 package writer
 
-import "path/filepath"
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"time"
+)
+
+const defaultLockTimeout = 5 * time.Second
 
 type Client struct {
-	root string
+	root        string
+	lockTimeout time.Duration
+	reader      Syncer
+	templates   string
+	git         *committer
+	hooks       []Hook
+	protected   []string
+}
+
+// Syncer is notified of each document the client writes, see reader.Client.Sync
+type Syncer interface {
+	Sync(path string) error
+}
+
+type clientOptions func(*Client)
+
+// Set how long to wait for another process to release its lock on a document before giving up
+func WithLockTimeout(timeout time.Duration) clientOptions {
+	return func(client *Client) {
+		client.lockTimeout = timeout
+	}
+}
+
+// Notify the reader after each successful write so its cache and events reflect the change before the write returns
+func WithReader(reader Syncer) clientOptions {
+	return func(client *Client) {
+		client.reader = reader
+	}
+}
+
+func NewClient(root string, opts ...clientOptions) *Client {
+	client := &Client{root: root, lockTimeout: defaultLockTimeout, templates: defaultTemplateDir}
+	for _, opt := range opts {
+		opt(client)
+	}
+	return client
+}
+
+// The write has already succeeded so a failure to sync isn't returned, the reader's file watcher will catch up instead
+func (c Client) sync(path string) {
+	if c.reader == nil {
+		return
+	}
+	if err := c.reader.Sync(path); err != nil {
+		slog.Warn("failed to sync reader after write", "path", path, "error", err)
+	}
 }
 
-func NewClient(root string) *Client {
-	return &Client{root: root}
+// Record the write to be committed if git commits are enabled, falling back to the default message if there's no description
+func (c Client) commit(path string, description string, fallback string) {
+	if c.git == nil {
+		return
+	}
+	if description == "" {
+		description = fmt.Sprintf(fallback, path)
+	}
+	c.git.record(path, description)
 }
 
 func (c Client) abs(doc string) string {
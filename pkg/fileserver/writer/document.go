@@ -16,10 +16,13 @@ package writer
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"log/slog"
 	"os"
 	"path/filepath"
+	"slices"
 
 	"github.com/notedownorg/notedown/pkg/fileserver/reader"
 	"sigs.k8s.io/yaml"
@@ -29,6 +32,9 @@ type Document struct {
 	// Path is relative to root
 	Path     string
 	Checksum string
+
+	// Description of the change being made, used as the commit message when git commits are enabled
+	Description string
 }
 
 type FileExistsError struct {
@@ -39,7 +45,34 @@ func (e *FileExistsError) Error() string {
 	return fmt.Sprintf("file %s already exists", e.Filename)
 }
 
-func (c Client) Add(path string, metadata reader.Metadata, content []byte) error {
+// StaleWriteError is returned when a document has been modified since it was last read and the mutations
+// could not be safely reapplied to the latest content. Err holds the reason the mutations could not be reapplied.
+type StaleWriteError struct {
+	Path     string
+	Checksum string
+	Latest   string
+	Err      error
+}
+
+func (e *StaleWriteError) Error() string {
+	msg := fmt.Sprintf("file %s has been modified since last read, unable to write with stale data wanted: %s got: %s", e.Path, e.Latest, e.Checksum)
+	if e.Err != nil {
+		msg = fmt.Sprintf("%s: %v", msg, e.Err)
+	}
+	return msg
+}
+
+func (e *StaleWriteError) Unwrap() error {
+	return e.Err
+}
+
+// Create a new document, erroring if it already exists. Options can be used to create the document from a template.
+// A PermissionError is returned if the path is outside of the root or protected.
+func (c Client) Add(path string, metadata reader.Metadata, content []byte, opts ...AddOption) error {
+	if err := c.writable(path); err != nil {
+		return err
+	}
+
 	// Ensure the file does not exist
 	_, err := os.Stat(c.abs(path))
 	if err == nil {
@@ -49,61 +82,145 @@ func (c Client) Add(path string, metadata reader.Metadata, content []byte) error
 		return fmt.Errorf("failed to check if file exists: %w", err)
 	}
 
+	config := newAddConfig(opts)
+	metadata, content, err = c.applyTemplate(path, metadata, content, config)
+	if err != nil {
+		return err
+	}
+	b, err := c.render(path, metadata, content)
+	if err != nil {
+		return err
+	}
+
+	// Ensure the directory exists
+	if err := os.MkdirAll(filepath.Dir(c.abs(path)), 0755); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+
+	// Create the file
+	if err := os.WriteFile(c.abs(path), b, 0644); err != nil {
+		return err
+	}
+	c.sync(path)
+	c.commit(path, config.description, "notedown: add %s")
+	return nil
+}
+
+// Render the document and run it through the hooks
+func (c Client) render(path string, metadata reader.Metadata, content []byte) ([]byte, error) {
+	b, err := render(metadata, content)
+	if err != nil || len(c.hooks) == 0 {
+		return b, err
+	}
+	f, err := c.runHooks(path, nil, parseFile(b), nil)
+	if err != nil {
+		return nil, err
+	}
+	return f.bytes(), nil
+}
+
+func render(metadata reader.Metadata, content []byte) ([]byte, error) {
 	var b bytes.Buffer
 	if metadata != nil && len(metadata) > 0 {
 		md, err := yaml.Marshal(metadata)
 		if err != nil {
-			return fmt.Errorf("failed to marshal metadata: %w", err)
+			return nil, fmt.Errorf("failed to marshal metadata: %w", err)
 		}
 		b.WriteString("---\n")
 		b.Write(md)
 		b.WriteString("---\n")
 	}
 	b.Write(content)
-
-	// Ensure the directory exists
-	if err := os.MkdirAll(filepath.Dir(c.abs(path)), 0755); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
-	}
-
-	// Create the file
-	return os.WriteFile(c.abs(path), b.Bytes(), 0644)
+	return b.Bytes(), nil
 }
 
 // Update contents of a document. Mutations are applied in order and are atomeic.
 // If any mutation errors, the document will not be written to disk.
+// The document's byte order mark, newline style and final newline are preserved.
+// A PermissionError is returned if the path is outside of the root or protected.
+// Hooks are run against the result and can prevent the write by returning an error, which is wrapped in a HookError.
+// If the document has been modified since the checksum was taken, anchored mutations are reapplied to the latest
+// content and a StaleWriteError is returned if this isn't possible.
+// An advisory lock is held on the document for the duration of the update, if it cannot be acquired within the
+// client's lock timeout a LockContentionError is returned.
+// If the client has a reader, it is synced with the new content before returning.
 func (c Client) UpdateContent(doc Document, mutations ...LineMutation) error {
+	_, err := c.UpdateContentVersion(doc, mutations...)
+	return err
+}
+
+// UpdateContentVersion updates the document in the same way as UpdateContent, returning the checksum of the content
+// that was written. This is the checksum the reader will report once it has seen the write.
+func (c Client) UpdateContentVersion(doc Document, mutations ...LineMutation) (string, error) {
 	slog.Debug("updating content of document", "path", doc.Path)
+	if err := c.writable(doc.Path); err != nil {
+		return "", err
+	}
 
-	lines, frontmatter, err := readAndValidateFile(c.abs(doc.Path), doc.Checksum)
+	// Hold the lock until the write completes so other writers can't modify the file between reading and writing
+	unlock, err := c.lock(doc.Path)
 	if err != nil {
-		return fmt.Errorf("failed to validate document: %w", err)
+		return "", fmt.Errorf("failed to lock document: %w", err)
 	}
+	defer unlock()
 
-	// Split out the frontmatter if it exists as mutations assume it's not there
-	prefix := make([]string, 0)
-	if frontmatter != -1 {
-		prefix, lines = lines[:frontmatter], lines[frontmatter:]
+	b, err := os.ReadFile(c.abs(doc.Path))
+	if err != nil {
+		return "", fmt.Errorf("failed to validate document: %w", err)
 	}
 
-	for i, mutation := range mutations {
-		lines, err = mutation(doc.Checksum, lines)
-		if err != nil {
-			return fmt.Errorf("invalid line mutation at index %d, no mutations will be written to disk: %w", i, err)
-		}
+	f, err := c.mutate(doc, b, mutations...)
+	if err != nil {
+		return "", err
 	}
 
-	content := bytes.NewBuffer([]byte{})
-	for _, line := range append(prefix, lines...) {
-		content.WriteString(line)
-		content.WriteString("\n")
+	written := f.bytes()
+	if err := os.WriteFile(c.abs(doc.Path), written, 0644); err != nil {
+		return "", fmt.Errorf("failed to write document: %w", err)
 	}
+	c.sync(doc.Path)
+	c.commit(doc.Path, doc.Description, "notedown: update %s")
 
-	if err := os.WriteFile(c.abs(doc.Path), content.Bytes(), 0644); err != nil {
-		return fmt.Errorf("failed to write document: %w", err)
+	return fmt.Sprintf("%x", sha256.Sum256(written)), nil
+}
+
+// Validate the document's contents against its checksum and apply the mutations and hooks, returning the resulting file
+func (c Client) mutate(doc Document, b []byte, mutations ...LineMutation) (file, error) {
+	// If the document is stale, the checksum can no longer guard against overwriting changes so we replace it.
+	// This ensures that only mutations that are able to guard themselves (i.e. anchored mutations) can be applied.
+	checksum := doc.Checksum
+	f, err := validateFile(doc.Path, b, doc.Checksum)
+	var stale *StaleWriteError
+	if errors.As(err, &stale) {
+		slog.Debug("document is stale, attempting to reapply mutations to latest content", "path", doc.Path)
+		checksum = staleChecksum(stale.Latest)
+		if len(mutations) == 0 {
+			return file{}, fmt.Errorf("failed to validate document: %w", stale)
+		}
+	} else if err != nil {
+		return file{}, fmt.Errorf("failed to validate document: %w", err)
 	}
 
-	return nil
+	// Split out the frontmatter if it exists as mutations assume it's not there
+	old := slices.Clone(f.lines)
+	prefix, lines := make([]string, 0), f.lines
+	if f.frontmatter != -1 {
+		prefix, lines = lines[:f.frontmatter], lines[f.frontmatter:]
+	}
+
+	for i, mutation := range mutations {
+		lines, err = mutation(checksum, lines)
+		if err != nil {
+			err = fmt.Errorf("invalid line mutation at index %d, no mutations will be written to disk: %w", i, err)
+			if stale != nil {
+				stale.Err = err
+				return file{}, fmt.Errorf("failed to validate document: %w", stale)
+			}
+			return file{}, err
+		}
+	}
+	f.lines = append(prefix, lines...)
+	return c.runHooks(doc.Path, old, f, mutations)
 }
 
 // func (c Client) RemoveDocument(doc Document) error {
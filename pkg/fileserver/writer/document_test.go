@@ -15,6 +15,7 @@
 package writer_test
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -183,6 +184,21 @@ This line was updated
 			},
 			wantFinal: []byte("This line was updated\n"),
 		},
+		{
+			name: "Block mutations shift the lines of later mutations",
+			doc:  writer.Document{Path: "basic.md", Checksum: basicChecksum},
+			mutations: []writer.LineMutation{
+				writer.AddBlock(1, Text("# Heading\n")),
+				writer.ReplaceLines(6, 8, Text("- [ ] Task\n  - [ ] Subtask")),
+				writer.RemoveLines(2, 4),
+				writer.UpdateLine(4, Text("  - [x] Subtask")),
+			},
+			wantFinal: []byte(`# Heading
+
+- [ ] Task
+  - [x] Subtask
+`),
+		},
 		{
 			name:      "File no longer exists",
 			doc:       writer.Document{Path: "does_not_exist.md", Checksum: ""},
@@ -218,3 +234,220 @@ This line was updated
 		})
 	}
 }
+
+// Golden tests compare the result of applying mutations to testdata/workspace/<file> against testdata/golden/<file>
+func TestUpdateDocument_Golden(t *testing.T) {
+	tests := []struct {
+		file      string
+		mutations []writer.LineMutation
+	}{
+		{
+			file: "basic.md",
+			mutations: []writer.LineMutation{
+				writer.AddLine(writer.AT_BEGINNING, Text("This line was added at the beginning")),
+				writer.RemoveLine(3),
+				writer.AddLine(3, Text("This line was added at line 3")),
+				writer.RemoveLine(6),
+				writer.UpdateLine(7, Text("This line was updated")),
+				writer.AddLine(999, Text("This line was added at line 999")),
+				writer.AddLine(writer.AT_END, Text("This line was added at the end")),
+			},
+		},
+		{
+			file:      "empty.md",
+			mutations: []writer.LineMutation{},
+		},
+		{
+			file: "frontmatter.md",
+			mutations: []writer.LineMutation{
+				writer.AddLine(writer.AT_BEGINNING, Text("This line was added at the beginning but should be after frontmatter")),
+			},
+		},
+		{
+			file: "crlf.md",
+			mutations: []writer.LineMutation{
+				writer.AddLine(writer.AT_BEGINNING, Text("This line was added at the beginning")),
+				writer.UpdateLine(6, Text("This line was updated")),
+				writer.AddLine(writer.AT_END, Text("This line was added at the end")),
+			},
+		},
+		{
+			file: "bom.md",
+			mutations: []writer.LineMutation{
+				writer.AddLine(writer.AT_BEGINNING, Text("This line was added at the beginning but should be after frontmatter")),
+			},
+		},
+		{
+			file: "no_final_newline.md",
+			mutations: []writer.LineMutation{
+				writer.UpdateLine(3, Text("This line was updated")),
+				writer.AddLine(writer.AT_END, Text("This line was added at the end")),
+			},
+		},
+	}
+	for _, tt := range tests {
+		dir, err := copyTestData(t.Name())
+		if err != nil {
+			t.Fatalf("failed to copy test data: %v", err)
+		}
+		client := writer.NewClient(dir)
+
+		t.Run(tt.file, func(t *testing.T) {
+			doc := loadDocument(t, dir, tt.file)
+			assert.NoError(t, client.UpdateContent(doc.Document, tt.mutations...))
+
+			got, err := os.ReadFile(filepath.Join(dir, tt.file))
+			assert.NoError(t, err)
+			want, err := os.ReadFile(filepath.Join("testdata/golden", tt.file))
+			assert.NoError(t, err)
+			assert.Equal(t, string(want), string(got))
+		})
+	}
+}
+
+func TestUpdateDocument_Stale(t *testing.T) {
+	updated := "This line is here to be updated"
+	tests := []struct {
+		name         string
+		mutations    []writer.LineMutation
+		wantFinal    []byte
+		wantConflict bool
+	}{
+		{
+			name: "Anchored mutations are reapplied to the latest content",
+			mutations: []writer.LineMutation{
+				writer.Anchored(7, updated, func(line int) writer.LineMutation { return writer.UpdateLine(line, Text("This line was updated")) }),
+			},
+			wantFinal: []byte(`Someone else added this line
+This is a basic document
+
+It has no front matter
+
+This line is here to be deleted
+
+This line was updated
+`),
+		},
+		{
+			name: "Mutations that aren't anchored are rejected",
+			mutations: []writer.LineMutation{
+				writer.UpdateLine(7, Text("This line was updated")),
+			},
+		},
+		{
+			name: "Appending isn't anchored so is rejected",
+			mutations: []writer.LineMutation{
+				writer.AddLine(writer.AT_END, Text("This line was added at the end")),
+			},
+		},
+		{
+			name: "Anchored mutations are rejected alongside ones that aren't",
+			mutations: []writer.LineMutation{
+				writer.Anchored(7, updated, func(line int) writer.LineMutation { return writer.UpdateLine(line, Text("This line was updated")) }),
+				writer.AddBlock(writer.AT_BEGINNING, Text("This block was added at the start")),
+			},
+		},
+		{
+			name: "Anchored mutations that conflict are rejected",
+			mutations: []writer.LineMutation{
+				writer.Anchored(5, "This line has been changed", func(line int) writer.LineMutation { return writer.RemoveLine(line) }),
+			},
+			wantConflict: true,
+		},
+	}
+	for _, tt := range tests {
+		dir, err := copyTestData(t.Name())
+		if err != nil {
+			t.Fatalf("failed to copy test data: %v", err)
+		}
+		client := writer.NewClient(dir)
+
+		t.Run(tt.name, func(t *testing.T) {
+			// Someone else modifies the document after we've read it
+			doc := loadDocument(t, dir, "basic.md")
+			if err := os.WriteFile(filepath.Join(dir, "basic.md"), append([]byte("Someone else added this line\n"), doc.Contents...), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := client.UpdateContent(doc.Document, tt.mutations...)
+			if tt.wantFinal == nil {
+				var stale *writer.StaleWriteError
+				assert.True(t, errors.As(err, &stale), "expected a stale write error got %v", err)
+				var conflict *writer.ConflictError
+				assert.Equal(t, tt.wantConflict, errors.As(err, &conflict))
+				return
+			}
+			assert.NoError(t, err)
+
+			contents, err := os.ReadFile(filepath.Join(dir, "basic.md"))
+			assert.NoError(t, err)
+			assert.Equal(t, string(tt.wantFinal), string(contents))
+		})
+	}
+}
+
+func TestUpdateDocument_AnchoredWithoutChecksum(t *testing.T) {
+	dir, err := copyTestData(t.Name())
+	if err != nil {
+		t.Fatalf("failed to copy test data: %v", err)
+	}
+	client := writer.NewClient(dir)
+
+	// Anchoring only stands in for the checksum when the document is stale, it can't be used to skip providing one
+	doc := loadDocument(t, dir, "basic.md")
+	doc.Checksum = ""
+	err = client.UpdateContent(doc.Document, writer.Anchored(7, "This line is here to be updated", func(line int) writer.LineMutation {
+		return writer.UpdateLine(line, Text("This line was updated"))
+	}))
+	assert.ErrorContains(t, err, "hash must be provided")
+
+	contents, err := os.ReadFile(filepath.Join(dir, "basic.md"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(contents), "This line was updated")
+}
+
+func TestUpdateDocument_Version(t *testing.T) {
+	dir, err := copyTestData(t.Name())
+	if err != nil {
+		t.Fatalf("failed to copy test data: %v", err)
+	}
+	client := writer.NewClient(dir)
+
+	// The returned version is the checksum of the document as written
+	doc := loadDocument(t, dir, "basic.md")
+	version, err := client.UpdateContentVersion(doc.Document, writer.AddLine(writer.AT_END, Text("Added")))
+	assert.NoError(t, err)
+	assert.NotEqual(t, doc.Checksum, version)
+	assert.Equal(t, loadDocument(t, dir, "basic.md").Checksum, version)
+
+	version, err = client.UpdateContentVersion(writer.Document{Path: "basic.md", Checksum: "bad_checksum"})
+	assert.Error(t, err)
+	assert.Empty(t, version)
+}
+
+type recordingSyncer struct {
+	paths []string
+}
+
+func (r *recordingSyncer) Sync(path string) error {
+	r.paths = append(r.paths, path)
+	return nil
+}
+
+func TestUpdateDocument_SyncsReader(t *testing.T) {
+	dir, err := copyTestData(t.Name())
+	if err != nil {
+		t.Fatalf("failed to copy test data: %v", err)
+	}
+	syncer := &recordingSyncer{}
+	client := writer.NewClient(dir, writer.WithReader(syncer))
+
+	assert.NoError(t, client.Add("synced.md", nil, []byte("Hello, world!\n")))
+	assert.NoError(t, client.UpdateContent(writer.Document{Path: "basic.md"}, writer.AddLine(writer.AT_END, Text("Added"))))
+
+	// Failed writes aren't synced
+	assert.Error(t, client.UpdateContent(writer.Document{Path: "basic.md", Checksum: "bad_checksum"}))
+	assert.Error(t, client.Add("basic.md", nil, nil))
+
+	assert.Equal(t, []string{"synced.md", "basic.md"}, syncer.paths)
+}
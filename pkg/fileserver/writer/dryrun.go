@@ -0,0 +1,157 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/notedownorg/notedown/pkg/fileserver/reader"
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// Diff is the change a dry run would make to a single document
+type Diff struct {
+	// Path is relative to root
+	Path string
+
+	// Before is nil if the document would be created
+	Before []byte
+	After  []byte
+}
+
+// Render the change as a unified diff
+func (d Diff) Unified() string {
+	from := "a/" + d.Path
+	if d.Before == nil {
+		from = "/dev/null"
+	}
+	diff, _ := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        splitLines(d.Before),
+		B:        splitLines(d.After),
+		FromFile: from,
+		ToFile:   "b/" + d.Path,
+		Context:  3,
+	})
+	return diff
+}
+
+func splitLines(b []byte) []string {
+	if len(b) == 0 {
+		return []string{}
+	}
+	lines := strings.SplitAfter(string(b), "\n")
+	if lines[len(lines)-1] == "" {
+		return lines[:len(lines)-1]
+	}
+	lines[len(lines)-1] += "\n"
+	return lines
+}
+
+// DryRun runs the same validation and mutations as the client but records the changes instead of writing them to disk.
+// Changes accumulate so later writes to the same document see the result of earlier ones.
+type DryRun struct {
+	client Client
+
+	mutex   sync.Mutex
+	order   []string
+	changes map[string]*Diff
+}
+
+// Create a dry run that reads from the client's root but never writes to it
+func (c Client) DryRun() *DryRun {
+	return &DryRun{client: c, changes: make(map[string]*Diff)}
+}
+
+func (d *DryRun) Add(path string, metadata reader.Metadata, content []byte, opts ...AddOption) error {
+	if err := d.client.writable(path); err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, ok := d.changes[path]; ok {
+		return &FileExistsError{Filename: path}
+	}
+	_, err := os.Stat(d.client.abs(path))
+	if err == nil {
+		return &FileExistsError{Filename: path}
+	}
+	if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check if file exists: %w", err)
+	}
+
+	metadata, content, err = d.client.applyTemplate(path, metadata, content, newAddConfig(opts))
+	if err != nil {
+		return err
+	}
+	b, err := d.client.render(path, metadata, content)
+	if err != nil {
+		return err
+	}
+	d.record(path, nil, b)
+	return nil
+}
+
+func (d *DryRun) UpdateContent(doc Document, mutations ...LineMutation) error {
+	slog.Debug("dry run updating content of document", "path", doc.Path)
+	if err := d.client.writable(doc.Path); err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	var before []byte
+	if change, ok := d.changes[doc.Path]; ok {
+		before = change.After
+	} else {
+		b, err := os.ReadFile(d.client.abs(doc.Path))
+		if err != nil {
+			return fmt.Errorf("failed to validate document: %w", err)
+		}
+		before = b
+	}
+
+	f, err := d.client.mutate(doc, before, mutations...)
+	if err != nil {
+		return err
+	}
+	d.record(doc.Path, before, f.bytes())
+	return nil
+}
+
+func (d *DryRun) record(path string, before []byte, after []byte) {
+	if change, ok := d.changes[path]; ok {
+		change.After = after
+		return
+	}
+	d.order = append(d.order, path)
+	d.changes[path] = &Diff{Path: path, Before: before, After: after}
+}
+
+// The changes that would have been made, one per document in the order they were first changed
+func (d *DryRun) Diffs() []Diff {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	res := make([]Diff, 0, len(d.order))
+	for _, path := range d.order {
+		res = append(res, *d.changes[path])
+	}
+	return res
+}
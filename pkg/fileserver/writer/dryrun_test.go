@@ -0,0 +1,83 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/notedownorg/notedown/pkg/fileserver/reader"
+	"github.com/notedownorg/notedown/pkg/fileserver/writer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDryRun(t *testing.T) {
+	dir, err := copyTestData(t.Name())
+	if err != nil {
+		t.Fatalf("failed to copy test data: %v", err)
+	}
+	client := writer.NewClient(dir)
+	dryRun := client.DryRun()
+
+	// Updates to the same document accumulate, the second uses the checksum from disk so is stale and relies on the anchor
+	basic := loadDocument(t, dir, "basic.md")
+	assert.NoError(t, dryRun.UpdateContent(basic.Document, writer.UpdateLine(7, Text("This line was updated"))))
+	assert.NoError(t, dryRun.UpdateContent(basic.Document, writer.Anchored(5, "This line is here to be deleted", writer.RemoveLine)))
+	assert.NoError(t, dryRun.Add("new.md", reader.Metadata{"type": "note"}, []byte("Hello, world!\n")))
+
+	// Validation failures are returned just as they would be when writing
+	err = dryRun.UpdateContent(basic.Document, writer.UpdateLine(1, Text("Not anchored")))
+	var stale *writer.StaleWriteError
+	assert.True(t, errors.As(err, &stale), "expected stale write error, got %v", err)
+	var exists *writer.FileExistsError
+	assert.True(t, errors.As(dryRun.Add("basic.md", nil, nil), &exists))
+	assert.True(t, errors.As(dryRun.Add("new.md", nil, nil), &exists))
+
+	diffs := dryRun.Diffs()
+	assert.Len(t, diffs, 2)
+
+	assert.Equal(t, "basic.md", diffs[0].Path)
+	assert.Equal(t, basic.Contents, diffs[0].Before)
+	assert.Equal(t, "This is a basic document\n\nIt has no front matter\n\n\nThis line was updated\n", string(diffs[0].After))
+	assert.Equal(t, `--- a/basic.md
++++ b/basic.md
+@@ -2,6 +2,5 @@
+ 
+ It has no front matter
+ 
+-This line is here to be deleted
+ 
+-This line is here to be updated
++This line was updated
+`, diffs[0].Unified())
+
+	assert.Equal(t, "new.md", diffs[1].Path)
+	assert.Nil(t, diffs[1].Before)
+	assert.Equal(t, `--- /dev/null
++++ b/new.md
+@@ -0,0 +1,4 @@
++---
++type: note
++---
++Hello, world!
+`, diffs[1].Unified())
+
+	// Nothing is written to disk
+	assert.Equal(t, basic.Contents, loadDocument(t, dir, "basic.md").Contents)
+	_, err = os.Stat(filepath.Join(dir, "new.md"))
+	assert.True(t, os.IsNotExist(err))
+}
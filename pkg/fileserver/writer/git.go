@@ -0,0 +1,161 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os/exec"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Commit each successful write to the git repository containing the workspace. Writes within the window of the first
+// uncommitted write are batched into a single commit, a window of 0 commits every write immediately.
+// Only the written documents are committed so any other changes in the repository are left untouched.
+// Pending commits are made in the background once the window closes, call Flush to commit them immediately.
+func WithGitCommits(window time.Duration) clientOptions {
+	return func(client *Client) {
+		client.git = &committer{root: client.root, window: window}
+	}
+}
+
+// Commit any writes that are waiting for their batch window to close
+func (c Client) Flush() error {
+	if c.git == nil {
+		return nil
+	}
+	return c.git.flush()
+}
+
+type commit struct {
+	path    string
+	message string
+}
+
+type committer struct {
+	root   string
+	window time.Duration
+
+	mutex   sync.Mutex
+	pending []commit
+	timer   *time.Timer
+}
+
+func (g *committer) record(path string, message string) {
+	g.mutex.Lock()
+	g.pending = append(g.pending, commit{path: path, message: message})
+	if g.window > 0 {
+		if g.timer == nil {
+			g.timer = time.AfterFunc(g.window, func() {
+				if err := g.flush(); err != nil {
+					slog.Error("failed to commit changes", "error", err)
+				}
+			})
+		}
+		g.mutex.Unlock()
+		return
+	}
+	g.mutex.Unlock()
+
+	if err := g.flush(); err != nil {
+		slog.Error("failed to commit changes", "error", err)
+	}
+}
+
+func (g *committer) flush() error {
+	g.mutex.Lock()
+	defer g.mutex.Unlock()
+	if g.timer != nil {
+		g.timer.Stop()
+		g.timer = nil
+	}
+	if len(g.pending) == 0 {
+		return nil
+	}
+	pending := g.pending
+	g.pending = nil
+
+	paths := make([]string, 0, len(pending))
+	seen := make(map[string]bool)
+	for _, c := range pending {
+		if !seen[c.path] {
+			paths = append(paths, c.path)
+			seen[c.path] = true
+		}
+	}
+
+	slog.Debug("committing changes", "paths", paths)
+	if err := g.run(append([]string{"add", "--"}, paths...)...); err != nil {
+		return err
+	}
+
+	// Writes that left a document as it was have nothing to commit, git refuses to make a commit with no changes
+	changed := make(map[string]bool)
+	for _, path := range paths {
+		staged, err := g.staged(path)
+		if err != nil {
+			return err
+		}
+		changed[path] = staged
+	}
+	paths = slices.DeleteFunc(paths, func(path string) bool { return !changed[path] })
+	pending = slices.DeleteFunc(pending, func(c commit) bool { return !changed[c.path] })
+	if len(paths) == 0 {
+		slog.Debug("no changes to commit")
+		return nil
+	}
+
+	// Passing the paths to commit ensures only they are committed, not anything else the user has staged
+	return g.run(append([]string{"commit", "--quiet", "--message", commitMessage(pending), "--"}, paths...)...)
+}
+
+// Whether the path has changes staged to be committed
+func (g *committer) staged(path string) (bool, error) {
+	err := g.run("diff", "--cached", "--quiet", "--", path)
+	var exit *exec.ExitError
+	if errors.As(err, &exit) && exit.ExitCode() == 1 {
+		return true, nil
+	}
+	return false, err
+}
+
+func (g *committer) run(args ...string) error {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = g.root
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("git %s failed: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// A single change uses its own message, batches are summarised with each change listed in the body
+func commitMessage(pending []commit) string {
+	if len(pending) == 1 {
+		return pending[0].message
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "notedown: %d changes\n\n", len(pending))
+	for _, c := range pending {
+		fmt.Fprintf(&b, "- %s\n", c.message)
+	}
+	return b.String()
+}
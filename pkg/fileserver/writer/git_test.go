@@ -0,0 +1,122 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer_test
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notedownorg/notedown/pkg/fileserver/writer"
+	"github.com/stretchr/testify/assert"
+)
+
+func git(t *testing.T, dir string, args ...string) string {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %s: %v: %s", strings.Join(args, " "), err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+// Use a temporary directory rather than setupTestDir to avoid nesting a repository in the CI workspace
+func setupGitRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	dir := t.TempDir()
+	git(t, dir, "init", "--quiet")
+	git(t, dir, "config", "user.name", "Notedown")
+	git(t, dir, "config", "user.email", "notedown@example.com")
+	if err := os.WriteFile(filepath.Join(dir, "basic.md"), []byte("line 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, dir, "add", "basic.md")
+	git(t, dir, "commit", "--quiet", "--message", "initial")
+	return dir
+}
+
+func TestGitCommits(t *testing.T) {
+	dir := setupGitRepo(t)
+
+	// Changes made by the user should never be swept into a commit
+	if err := os.WriteFile(filepath.Join(dir, "manual.md"), []byte("manual\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	git(t, dir, "add", "manual.md")
+
+	client := writer.NewClient(dir, writer.WithGitCommits(0))
+	doc := writer.Document{Path: "basic.md", Description: "tasks: complete 'Write spec' in basic.md"}
+	assert.NoError(t, client.UpdateContent(doc, writer.AddLine(writer.AT_END, Text("line 2"))))
+	assert.NoError(t, client.Add("added.md", nil, []byte("added\n")))
+
+	assert.Equal(t, "notedown: add added.md\ntasks: complete 'Write spec' in basic.md\ninitial", git(t, dir, "log", "--format=%s"))
+	assert.Equal(t, "added.md", git(t, dir, "show", "--name-only", "--format=", "HEAD"))
+	assert.Equal(t, "manual.md", git(t, dir, "diff", "--cached", "--name-only"))
+}
+
+func TestGitCommits_Batched(t *testing.T) {
+	dir := setupGitRepo(t)
+	client := writer.NewClient(dir, writer.WithGitCommits(time.Hour))
+
+	assert.NoError(t, client.UpdateContent(writer.Document{Path: "basic.md"}, writer.AddLine(writer.AT_END, Text("line 2"))))
+	assert.NoError(t, client.Add("added.md", nil, []byte("added\n"), writer.WithDescription("daily: create added")))
+	assert.NoError(t, client.UpdateContent(writer.Document{Path: "basic.md"}, writer.AddLine(writer.AT_END, Text("line 3"))))
+	assert.Equal(t, "initial", git(t, dir, "log", "--format=%s"), "expected nothing to be committed until the window closes")
+
+	assert.NoError(t, client.Flush())
+	assert.Equal(t, "notedown: 3 changes\n\n- notedown: update basic.md\n- daily: create added\n- notedown: update basic.md", git(t, dir, "log", "-1", "--format=%B"))
+	assert.Equal(t, "added.md\nbasic.md", git(t, dir, "show", "--name-only", "--format=", "HEAD"))
+	assert.Equal(t, "", git(t, dir, "status", "--porcelain"))
+
+	// Nothing left to commit
+	assert.NoError(t, client.Flush())
+	assert.Equal(t, "2", git(t, dir, "rev-list", "--count", "HEAD"))
+}
+
+func TestGitCommits_Unchanged(t *testing.T) {
+	dir := setupGitRepo(t)
+	client := writer.NewClient(dir, writer.WithGitCommits(time.Hour))
+
+	// Writing a document without changing it leaves nothing to commit
+	assert.NoError(t, client.UpdateContent(writer.Document{Path: "basic.md"}))
+	assert.NoError(t, client.Flush())
+	assert.Equal(t, "1", git(t, dir, "rev-list", "--count", "HEAD"))
+
+	// Only the documents that changed are committed and described
+	assert.NoError(t, client.UpdateContent(writer.Document{Path: "basic.md"}))
+	assert.NoError(t, client.Add("added.md", nil, []byte("added\n"), writer.WithDescription("daily: create added")))
+	assert.NoError(t, client.Flush())
+	assert.Equal(t, "daily: create added", git(t, dir, "log", "-1", "--format=%B"))
+	assert.Equal(t, "added.md", git(t, dir, "show", "--name-only", "--format=", "HEAD"))
+}
+
+func TestGitCommits_Window(t *testing.T) {
+	dir := setupGitRepo(t)
+	client := writer.NewClient(dir, writer.WithGitCommits(50*time.Millisecond))
+
+	assert.NoError(t, client.UpdateContent(writer.Document{Path: "basic.md"}, writer.AddLine(writer.AT_END, Text("line 2"))))
+	assert.Eventually(t, func() bool {
+		cmd := exec.Command("git", "rev-list", "--count", "HEAD")
+		cmd.Dir = dir
+		out, err := cmd.Output()
+		return err == nil && strings.TrimSpace(string(out)) == "2"
+	}, 5*time.Second, 10*time.Millisecond)
+}
@@ -0,0 +1,81 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"fmt"
+	"strings"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Hook is called with the proposed content of a document before it is written, lines include any frontmatter.
+// Old is nil when the document is being added and mutations is nil unless the document is being updated.
+// Returning an error prevents the write, otherwise the returned lines are written in place of new so hooks
+// can transform the content. Hooks are called in the order they were registered, each receiving the previous one's lines.
+type Hook func(path string, old []string, new []string, mutations []LineMutation) ([]string, error)
+
+// HookError is returned when a hook prevents a write, Err is the error returned by the hook.
+type HookError struct {
+	Path string
+
+	// The position of the hook in the order they were registered
+	Index int
+	Err   error
+}
+
+func (e *HookError) Error() string {
+	return fmt.Sprintf("hook %d rejected write to file %s: %v", e.Index, e.Path, e.Err)
+}
+
+func (e *HookError) Unwrap() error {
+	return e.Err
+}
+
+// Register hooks to be called before each document is written
+func WithHooks(hooks ...Hook) clientOptions {
+	return func(client *Client) {
+		client.hooks = append(client.hooks, hooks...)
+	}
+}
+
+func (c Client) runHooks(path string, old []string, f file, mutations []LineMutation) (file, error) {
+	for i, hook := range c.hooks {
+		lines, err := hook(path, old, f.lines, mutations)
+		if err != nil {
+			return file{}, &HookError{Path: path, Index: i, Err: err}
+		}
+		f.lines = lines
+	}
+	return f, nil
+}
+
+// ValidFrontmatter is a hook that rejects writes that would leave a document with frontmatter the reader can't parse
+func ValidFrontmatter(path string, old []string, new []string, mutations []LineMutation) ([]string, error) {
+	if len(new) == 0 || strings.TrimSpace(new[0]) != "---" {
+		return new, nil
+	}
+	for i, line := range new[1:] {
+		if strings.HasPrefix(line, "---") {
+			if _, err := yaml.YAMLToJSON([]byte(strings.Join(new[1:i+1], "\n"))); err != nil {
+				return nil, fmt.Errorf("invalid frontmatter: %w", err)
+			}
+			return new, nil
+		}
+	}
+
+	// Without a closing delimiter there is no frontmatter
+	return new, nil
+}
@@ -0,0 +1,137 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/notedownorg/notedown/pkg/fileserver/writer"
+	"github.com/stretchr/testify/assert"
+)
+
+var errSecret = errors.New("contains a secret")
+
+func vetoSecrets(path string, old []string, new []string, mutations []writer.LineMutation) ([]string, error) {
+	for _, line := range new {
+		if strings.Contains(line, "password") {
+			return nil, errSecret
+		}
+	}
+	return new, nil
+}
+
+func redactTokens(path string, old []string, new []string, mutations []writer.LineMutation) ([]string, error) {
+	res := make([]string, len(new))
+	for i, line := range new {
+		res[i] = strings.ReplaceAll(line, "token=abc", "token=REDACTED")
+	}
+	return res, nil
+}
+
+func TestHooks(t *testing.T) {
+	dir, err := copyTestData(t.Name())
+	if err != nil {
+		t.Fatalf("failed to copy test data: %v", err)
+	}
+
+	type call struct {
+		path      string
+		old       []string
+		new       []string
+		mutations int
+	}
+	var calls []call
+	record := func(path string, old []string, new []string, mutations []writer.LineMutation) ([]string, error) {
+		calls = append(calls, call{path: path, old: old, new: new, mutations: len(mutations)})
+		return new, nil
+	}
+	client := writer.NewClient(dir, writer.WithHooks(redactTokens, vetoSecrets), writer.WithHooks(record))
+
+	// Hooks see the whole document including frontmatter and receive the previous hook's lines
+	before := loadDocument(t, dir, "frontmatter.md")
+	assert.NoError(t, client.UpdateContent(before.Document, writer.AddLine(writer.AT_END, Text("token=abc"))))
+	after := loadDocument(t, dir, "frontmatter.md")
+	assert.Equal(t, string(before.Contents)+"token=REDACTED\n", string(after.Contents))
+	assert.Len(t, calls, 1)
+	assert.Equal(t, "frontmatter.md", calls[0].path)
+	assert.Equal(t, strings.Split(strings.TrimSuffix(string(before.Contents), "\n"), "\n"), calls[0].old)
+	assert.Equal(t, strings.Split(strings.TrimSuffix(string(after.Contents), "\n"), "\n"), calls[0].new)
+	assert.Equal(t, 1, calls[0].mutations)
+
+	// Adding a document has no old content or mutations
+	assert.NoError(t, client.Add("added.md", nil, []byte("token=abc\n")))
+	contents, err := os.ReadFile(filepath.Join(dir, "added.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, "token=REDACTED\n", string(contents))
+	assert.Equal(t, call{path: "added.md", new: []string{"token=REDACTED"}}, calls[1])
+
+	// A veto prevents the write and stops later hooks from running
+	err = client.UpdateContent(after.Document, writer.AddLine(writer.AT_END, Text("password=hunter2")))
+	var hookErr *writer.HookError
+	assert.True(t, errors.As(err, &hookErr), "expected hook error, got %v", err)
+	assert.Equal(t, "frontmatter.md", hookErr.Path)
+	assert.Equal(t, 1, hookErr.Index)
+	assert.ErrorIs(t, err, errSecret)
+	assert.Equal(t, after.Contents, loadDocument(t, dir, "frontmatter.md").Contents)
+	assert.ErrorIs(t, client.Add("vetoed.md", nil, []byte("password=hunter2\n")), errSecret)
+	_, err = os.Stat(filepath.Join(dir, "vetoed.md"))
+	assert.True(t, os.IsNotExist(err))
+	assert.Len(t, calls, 2)
+
+	// Dry runs run the same hooks
+	dryRun := client.DryRun()
+	assert.ErrorIs(t, dryRun.UpdateContent(after.Document, writer.AddLine(writer.AT_END, Text("password=hunter2"))), errSecret)
+	assert.NoError(t, dryRun.UpdateContent(after.Document, writer.AddLine(writer.AT_END, Text("token=abc"))))
+	assert.Equal(t, string(after.Contents)+"token=REDACTED\n", string(dryRun.Diffs()[0].After))
+}
+
+func TestHooks_ValidFrontmatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		lines   []string
+		wantErr bool
+	}{
+		{name: "No frontmatter", lines: []string{"# Heading", "key: [invalid"}},
+		{name: "Empty document", lines: []string{}},
+		{name: "Valid frontmatter", lines: []string{"---", "type: note", "tags: [a, b]", "---", "# Heading"}},
+		{name: "Empty frontmatter", lines: []string{"---", "---"}},
+		{name: "Unclosed delimiter is not frontmatter", lines: []string{"---", "key: [invalid"}},
+		{name: "Invalid frontmatter", lines: []string{"---", "key: [invalid", "---", "# Heading"}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines, err := writer.ValidFrontmatter("path", nil, tt.lines, nil)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.lines, lines)
+		})
+	}
+
+	dir, err := copyTestData(t.Name())
+	if err != nil {
+		t.Fatalf("failed to copy test data: %v", err)
+	}
+	client := writer.NewClient(dir, writer.WithHooks(writer.ValidFrontmatter))
+	var hookErr *writer.HookError
+	assert.True(t, errors.As(client.Add("invalid.md", nil, []byte("---\nkey: [invalid\n---\n")), &hookErr))
+	assert.NoError(t, client.Add("valid.md", nil, []byte("---\nkey: [valid]\n---\n")))
+}
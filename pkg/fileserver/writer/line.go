@@ -35,7 +35,10 @@ func AddLine(number int, obj fmt.Stringer) LineMutation {
 		if err := validateLine(obj.String()); err != nil {
 			return lines, fmt.Errorf("invalid text adding line '%s': %w", obj, err)
 		}
-		if checksum == "" && number != AT_END && number != AT_BEGINNING {
+		if stale(checksum) {
+			return lines, fmt.Errorf("document has been modified since it was read")
+		}
+		if unguarded(checksum) && number != AT_END && number != AT_BEGINNING {
 			return lines, fmt.Errorf("hash must be provided when adding a line in the middle of a document")
 		}
 
@@ -58,7 +61,7 @@ func AddLine(number int, obj fmt.Stringer) LineMutation {
 // e.g. if the frontmatter ends at line 3 in the underlying file, removing a line at 1 will remove the line at 4
 func RemoveLine(number int) LineMutation {
 	return func(checksum string, lines []string) ([]string, error) {
-		if checksum == "" {
+		if unguarded(checksum) {
 			return lines, fmt.Errorf("hash must be provided when removing a line to avoid stale writes")
 		}
 		if number == AT_END || number == AT_BEGINNING {
@@ -81,7 +84,7 @@ func UpdateLine(number int, obj fmt.Stringer) LineMutation {
 		if err := validateLine(obj.String()); err != nil {
 			return lines, fmt.Errorf("invalid text updating line '%s': %w", obj, err)
 		}
-		if checksum == "" {
+		if unguarded(checksum) {
 			return lines, fmt.Errorf("hash must be provided when updating a line to avoid stale writes")
 		}
 		if number == AT_END || number == AT_BEGINNING {
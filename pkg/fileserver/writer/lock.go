@@ -0,0 +1,63 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"fmt"
+	"os"
+	"time"
+)
+
+// How often to retry acquiring a lock that is held by another process
+const lockRetryInterval = 10 * time.Millisecond
+
+// LockContentionError is returned when another process holds the lock on a document for longer than the timeout
+type LockContentionError struct {
+	Path    string
+	Timeout time.Duration
+}
+
+func (e *LockContentionError) Error() string {
+	return fmt.Sprintf("timed out after %s waiting for lock on file %s", e.Timeout, e.Path)
+}
+
+// Take an exclusive advisory lock on the document so the read, validate and write cycle cannot interleave with
+// other processes (or clients) writing to the same file. The returned function releases the lock.
+func (c Client) lock(path string) (func(), error) {
+	f, err := os.Open(c.abs(path))
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(c.lockTimeout)
+	for {
+		locked, err := tryLock(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		if locked {
+			return func() {
+				unlock(f)
+				f.Close()
+			}, nil
+		}
+		if time.Now().After(deadline) {
+			f.Close()
+			return nil, &LockContentionError{Path: path, Timeout: c.lockTimeout}
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
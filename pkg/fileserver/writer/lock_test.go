@@ -0,0 +1,134 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build linux || darwin || dragonfly || freebsd || netbsd || openbsd
+
+package writer_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/notedownorg/notedown/pkg/fileserver/writer"
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	lockHelperEnv = "NOTEDOWN_WRITER_LOCK_HELPER"
+	lockProcesses = 4
+	lockWrites    = 25
+)
+
+// When run as a helper process, append lines to the given document as fast as possible.
+// Without locking, concurrent read/write cycles lose each other's lines.
+func TestLock_Helper(t *testing.T) {
+	root, path := os.Getenv(lockHelperEnv), os.Getenv(lockHelperEnv+"_PATH")
+	if root == "" {
+		t.Skip("only runs as a helper process")
+	}
+	id := os.Getenv(lockHelperEnv + "_ID")
+	client := writer.NewClient(root, writer.WithLockTimeout(30*time.Second))
+	for i := 0; i < lockWrites; i++ {
+		line := stringer{text: fmt.Sprintf("- [ ] Process %s write %d", id, i)}
+		if err := client.UpdateContent(writer.Document{Path: path}, writer.AddLine(writer.AT_END, line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLock_MultipleProcesses(t *testing.T) {
+	dir, err := setupTestDir(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "hammered.md"
+	if err := os.WriteFile(filepath.Join(dir, path), []byte("# Hammered\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cmds := make([]*exec.Cmd, lockProcesses)
+	for i := range cmds {
+		cmds[i] = exec.Command(os.Args[0], "-test.run=^TestLock_Helper$")
+		cmds[i].Env = append(os.Environ(),
+			lockHelperEnv+"="+dir,
+			lockHelperEnv+"_PATH="+path,
+			lockHelperEnv+"_ID="+strconv.Itoa(i),
+		)
+		if err := cmds[i].Start(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i, cmd := range cmds {
+		if err := cmd.Wait(); err != nil {
+			t.Fatalf("helper process %d failed: %v", i, err)
+		}
+	}
+
+	b, err := os.ReadFile(filepath.Join(dir, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(string(b), "\n"), "\n")
+	assert.Equal(t, "# Hammered", lines[0])
+	assert.Len(t, lines[1:], lockProcesses*lockWrites, "expected no writes to be lost")
+	for i := 0; i < lockProcesses; i++ {
+		for j := 0; j < lockWrites; j++ {
+			assert.Contains(t, lines, fmt.Sprintf("- [ ] Process %d write %d", i, j))
+		}
+	}
+}
+
+func TestLock_Contention(t *testing.T) {
+	dir, err := setupTestDir(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := "locked.md"
+	if err := os.WriteFile(filepath.Join(dir, path), []byte("line 1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold the lock from a separate file descriptor, as another process would
+	f, err := os.Open(filepath.Join(dir, path))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX); err != nil {
+		t.Fatal(err)
+	}
+
+	client := writer.NewClient(dir, writer.WithLockTimeout(50*time.Millisecond))
+	err = client.UpdateContent(writer.Document{Path: path}, writer.AddLine(writer.AT_END, stringer{text: "line 2"}))
+	var contention *writer.LockContentionError
+	assert.True(t, errors.As(err, &contention), "expected lock contention error, got %v", err)
+	assert.Equal(t, path, contention.Path)
+
+	// Once released, the write succeeds
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_UN); err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, client.UpdateContent(writer.Document{Path: path}, writer.AddLine(writer.AT_END, stringer{text: "line 2"})))
+	b, err := os.ReadFile(filepath.Join(dir, path))
+	assert.NoError(t, err)
+	assert.Equal(t, "line 1\nline 2\n", string(b))
+}
@@ -0,0 +1,133 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/notedownorg/notedown/internal/glob"
+)
+
+// PermissionError is returned when a path is outside of the root or protected from writes
+type PermissionError struct {
+	Path   string
+	Reason string
+}
+
+func (e *PermissionError) Error() string {
+	return fmt.Sprintf("permission denied for file %s: %s", e.Path, e.Reason)
+}
+
+// Protect paths matching any of the globs from being written to. Globs are relative to root, use / as the separator
+// and support ** to match any number of directories. A glob that matches a directory protects everything inside it.
+func WithProtected(globs ...string) clientOptions {
+	return func(client *Client) {
+		client.protected = append(client.protected, globs...)
+	}
+}
+
+// Ensure the path can be written to, returning a PermissionError if it is outside of the root or protected.
+// The globs are matched against the path as given and after following symlinks, so a symlink can't be used to write
+// to a protected path.
+func (c Client) writable(path string) error {
+	resolved, err := c.resolve(path)
+	if err != nil {
+		return err
+	}
+	for _, rel := range []string{filepath.ToSlash(filepath.Clean(path)), filepath.ToSlash(resolved)} {
+		if err := c.unprotected(path, rel); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c Client) unprotected(path string, rel string) error {
+	for _, pattern := range c.protected {
+		// Check each parent so protecting a directory also protects its contents
+		for p := rel; p != "." && p != "/"; p = filepath.ToSlash(filepath.Dir(p)) {
+			ok, err := glob.Match(pattern, p)
+			if err != nil {
+				return fmt.Errorf("invalid protected glob %s: %w", pattern, err)
+			}
+			if ok {
+				return &PermissionError{Path: path, Reason: fmt.Sprintf("protected by %s", pattern)}
+			}
+		}
+	}
+	return nil
+}
+
+// Ensure the path is inside of the root, including after following any symlinks
+func (c Client) sandboxed(path string) error {
+	_, err := c.resolve(path)
+	return err
+}
+
+// The path relative to the root after following any symlinks, a PermissionError is returned if it's outside of the root
+func (c Client) resolve(path string) (string, error) {
+	if !filepath.IsLocal(path) {
+		return "", &PermissionError{Path: path, Reason: "outside of root"}
+	}
+
+	root, err := evalExisting(c.root)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve root: %w", err)
+	}
+	resolved, err := evalExisting(c.abs(path))
+	if errors.Is(err, errBrokenSymlink) {
+		return "", &PermissionError{Path: path, Reason: err.Error()}
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+	rel, err := filepath.Rel(root, resolved)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", &PermissionError{Path: path, Reason: "resolves outside of root"}
+	}
+	return rel, nil
+}
+
+var errBrokenSymlink = errors.New("broken symlink")
+
+// Resolve symlinks in the longest part of the path that exists, the rest can't contain symlinks so is appended as is
+func evalExisting(name string) (string, error) {
+	suffix := ""
+	for {
+		resolved, err := filepath.EvalSymlinks(name)
+		if err == nil {
+			return filepath.Join(resolved, suffix), nil
+		}
+		if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+
+		// A broken symlink would be followed when writing so we can't tell where it would end up
+		if _, err := os.Lstat(name); err == nil {
+			return "", fmt.Errorf("%w %s", errBrokenSymlink, name)
+		}
+
+		parent := filepath.Dir(name)
+		if parent == name {
+			return "", err
+		}
+		suffix = filepath.Join(filepath.Base(name), suffix)
+		name = parent
+	}
+}
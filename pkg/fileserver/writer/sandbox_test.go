@@ -0,0 +1,163 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/notedownorg/notedown/pkg/fileserver/writer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSandbox(t *testing.T) {
+	dir, err := copyTestData(t.Name())
+	if err != nil {
+		t.Fatalf("failed to copy test data: %v", err)
+	}
+	outside := t.TempDir()
+	if err := os.WriteFile(filepath.Join(outside, "secret.md"), []byte("secret\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(outside, filepath.Join(dir, "escape")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "secret.md"), filepath.Join(dir, "secret.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(outside, "missing.md"), filepath.Join(dir, "broken.md")); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(dir, "parent"), filepath.Join(dir, "inside")); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		path    string
+		wantErr bool
+	}{
+		{name: "Relative path", path: "basic.md"},
+		{name: "Relative path that stays inside root", path: "parent/../basic.md"},
+		{name: "Symlink inside root", path: "inside/existing.md"},
+		{name: "Parent directory", path: "../basic.md", wantErr: true},
+		{name: "Nested parent directory", path: "parent/../../basic.md", wantErr: true},
+		{name: "Absolute path", path: filepath.Join(dir, "basic.md"), wantErr: true},
+		{name: "Symlinked directory outside root", path: "escape/secret.md", wantErr: true},
+		{name: "Symlinked file outside root", path: "secret.md", wantErr: true},
+		{name: "Broken symlink", path: "broken.md", wantErr: true},
+	}
+	client := writer.NewClient(dir)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := client.UpdateContent(writer.Document{Path: tt.path}, writer.AddLine(writer.AT_END, Text("added")))
+			dryRunErr := client.DryRun().UpdateContent(writer.Document{Path: tt.path}, writer.AddLine(writer.AT_END, Text("added")))
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				assert.NoError(t, dryRunErr)
+				return
+			}
+			var permission *writer.PermissionError
+			assert.True(t, errors.As(err, &permission), "expected permission error, got %v", err)
+			assert.Equal(t, tt.path, permission.Path)
+			assert.True(t, errors.As(dryRunErr, &permission), "expected permission error, got %v", dryRunErr)
+		})
+	}
+
+	// New files are checked the same way
+	var permission *writer.PermissionError
+	assert.True(t, errors.As(client.Add("escape/new.md", nil, nil), &permission))
+	assert.True(t, errors.As(client.Add("../new.md", nil, nil), &permission))
+	assert.True(t, errors.As(client.Add("new.md", nil, nil, writer.WithTemplate("../../template", writer.TemplateData{})), &permission))
+	assert.NoError(t, client.Add("inside/new/new.md", nil, nil))
+
+	// Nothing outside of the root was modified
+	contents, err := os.ReadFile(filepath.Join(outside, "secret.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, "secret\n", string(contents))
+	entries, err := os.ReadDir(outside)
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+}
+
+func TestProtected(t *testing.T) {
+	dir, err := copyTestData(t.Name())
+	if err != nil {
+		t.Fatalf("failed to copy test data: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "templates", "note.md"), []byte("# {{title}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	client := writer.NewClient(dir, writer.WithProtected("templates", "**/archive/**", "*.lock.md"))
+
+	tests := []struct {
+		path    string
+		wantErr bool
+	}{
+		{path: "new.md"},
+		{path: "templates/note.md", wantErr: true},
+		{path: "templates/new/note.md", wantErr: true},
+		{path: "projects/archive/old.md", wantErr: true},
+		{path: "archive/old.md", wantErr: true},
+		{path: "archived/old.md"},
+		{path: "frozen.lock.md", wantErr: true},
+		{path: "parent/frozen.lock.md"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			err := client.Add(tt.path, nil, []byte("added\n"))
+			if !tt.wantErr {
+				assert.NoError(t, err)
+				return
+			}
+			var permission *writer.PermissionError
+			assert.True(t, errors.As(err, &permission), "expected permission error, got %v", err)
+			assert.Equal(t, tt.path, permission.Path)
+		})
+	}
+
+	var permission *writer.PermissionError
+	err = client.UpdateContent(writer.Document{Path: "templates/note.md"}, writer.AddLine(writer.AT_END, Text("added")))
+	assert.True(t, errors.As(err, &permission), "expected permission error, got %v", err)
+
+	// Protected templates can still be used
+	assert.NoError(t, client.Add("from_template.md", nil, nil, writer.WithTemplate("note", writer.TemplateData{})))
+
+	// Symlinks into protected directories are protected too
+	if err := os.Symlink(filepath.Join(dir, "templates"), filepath.Join(dir, "shortcut")); err != nil {
+		t.Fatal(err)
+	}
+	err = client.Add("shortcut/sneaky.md", nil, []byte("added\n"))
+	assert.True(t, errors.As(err, &permission), "expected permission error, got %v", err)
+	err = client.UpdateContent(writer.Document{Path: "shortcut/note.md"}, writer.AddLine(writer.AT_END, Text("added")))
+	assert.True(t, errors.As(err, &permission), "expected permission error, got %v", err)
+	if err := os.Symlink(filepath.Join(dir, "templates", "note.md"), filepath.Join(dir, "linked.md")); err != nil {
+		t.Fatal(err)
+	}
+	err = client.UpdateContent(writer.Document{Path: "linked.md"}, writer.AddLine(writer.AT_END, Text("added")))
+	assert.True(t, errors.As(err, &permission), "expected permission error, got %v", err)
+	contents, err := os.ReadFile(filepath.Join(dir, "templates", "note.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, "# {{title}}\n", string(contents))
+
+	// Invalid globs fail closed
+	err = writer.NewClient(dir, writer.WithProtected("[")).Add("new.md", nil, nil)
+	assert.Error(t, err)
+}
@@ -0,0 +1,332 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/notedownorg/notedown/pkg/fileserver/reader"
+	"sigs.k8s.io/yaml"
+)
+
+const defaultTemplateDir = "templates"
+
+// The values available to a template. Title defaults to the name of the file being created and Date defaults to now.
+//
+// Placeholders are written as {{name}} and the following are always available:
+//   - {{title}}
+//   - {{date}}, {{yesterday}} and {{tomorrow}} formatted as YYYY-MM-DD
+//   - {{time}} formatted as HH:mm
+//
+// Date placeholders accept a format such as {{date:dddd D MMMM}} using YYYY, YY, MMMM, MMM, MM, M, DD, D, dddd, ddd,
+// W (ISO week), HH, hh, h, mm, ss, A and a. Text in square brackets is output as is e.g. {{date:[Week] W}}.
+//
+// Sections can be included conditionally with {{#if name}}...{{else}}...{{/if}}, a variable is true if it is not empty.
+type TemplateData struct {
+	Title string
+	Date  time.Time
+
+	// Additional variables available to the template
+	Vars map[string]string
+}
+
+type addConfig struct {
+	template    string
+	data        TemplateData
+	description string
+}
+
+type AddOption func(*addConfig)
+
+func newAddConfig(opts []AddOption) addConfig {
+	var config addConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+// Describe the document being added, used as the commit message when git commits are enabled
+func WithDescription(description string) AddOption {
+	return func(config *addConfig) {
+		config.description = description
+	}
+}
+
+// Create the document from a template in the client's template directory, the .md extension is optional.
+// The template's frontmatter is merged with the metadata passed to Add (which takes precedence) and any content
+// passed to Add is appended to the template's body.
+func WithTemplate(name string, data TemplateData) AddOption {
+	return func(config *addConfig) {
+		config.template = name
+		config.data = data
+	}
+}
+
+// Set the directory, relative to root, that templates are read from
+func WithTemplateDir(dir string) clientOptions {
+	return func(client *Client) {
+		client.templates = dir
+	}
+}
+
+// Apply the template (if any) to the metadata and content for the document being added
+func (c Client) applyTemplate(path string, metadata reader.Metadata, content []byte, config addConfig) (reader.Metadata, []byte, error) {
+	if config.template == "" {
+		return metadata, content, nil
+	}
+
+	// Templates are only read so don't need to be writable but must still be inside the root
+	name := filepath.Join(c.templates, strings.TrimSuffix(config.template, ".md")+".md")
+	if err := c.sandboxed(name); err != nil {
+		return nil, nil, err
+	}
+	b, err := os.ReadFile(c.abs(name))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read template %s: %w", config.template, err)
+	}
+
+	data := config.data
+	if data.Title == "" {
+		data.Title = strings.TrimSuffix(filepath.Base(path), ".md")
+	}
+	if data.Date.IsZero() {
+		data.Date = time.Now()
+	}
+	text, err := renderTemplate(string(b), data)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to render template %s: %w", config.template, err)
+	}
+
+	frontmatter, body := splitFrontmatter(text)
+	merged := reader.Metadata{}
+	if frontmatter != "" {
+		if err := yaml.Unmarshal([]byte(frontmatter), &merged); err != nil {
+			return nil, nil, fmt.Errorf("failed to parse template %s frontmatter: %w", config.template, err)
+		}
+	}
+	for k, v := range metadata {
+		merged[k] = v
+	}
+	return merged, append([]byte(body), content...), nil
+}
+
+// Split the frontmatter from the body, the frontmatter is returned without the --- delimiters
+func splitFrontmatter(text string) (string, string) {
+	lines := strings.SplitAfter(text, "\n")
+	if len(lines) == 0 || strings.TrimSpace(lines[0]) != "---" {
+		return "", text
+	}
+	for i, line := range lines[1:] {
+		if strings.TrimSpace(line) == "---" {
+			return strings.Join(lines[1:i+1], ""), strings.Join(lines[i+2:], "")
+		}
+	}
+	return "", text
+}
+
+type templateToken struct {
+	text string
+	tag  bool
+}
+
+func renderTemplate(text string, data TemplateData) (string, error) {
+	tokens, err := tokenizeTemplate(text)
+	if err != nil {
+		return "", err
+	}
+	var b strings.Builder
+	i, term, err := renderBlock(tokens, 0, data, &b, true)
+	if err != nil {
+		return "", err
+	}
+	if i < len(tokens) {
+		return "", fmt.Errorf("unexpected {{%s}} without a matching {{#if}}", term)
+	}
+	return b.String(), nil
+}
+
+func tokenizeTemplate(text string) ([]templateToken, error) {
+	tokens := make([]templateToken, 0)
+	for {
+		start := strings.Index(text, "{{")
+		if start == -1 {
+			return append(tokens, templateToken{text: text}), nil
+		}
+		end := strings.Index(text[start:], "}}")
+		if end == -1 {
+			return nil, fmt.Errorf("unclosed placeholder %q", text[start:])
+		}
+		tokens = append(tokens, templateToken{text: text[:start]}, templateToken{text: strings.TrimSpace(text[start+2 : start+end]), tag: true})
+		text = text[start+end+2:]
+	}
+}
+
+// Render tokens until the end of the template or an {{else}} or {{/if}}, returning the index of the terminating tag.
+// Output is only written if emit is true so branches that aren't taken are still validated.
+func renderBlock(tokens []templateToken, i int, data TemplateData, b *strings.Builder, emit bool) (int, string, error) {
+	for ; i < len(tokens); i++ {
+		token := tokens[i]
+		if !token.tag {
+			if emit {
+				b.WriteString(token.text)
+			}
+			continue
+		}
+
+		if token.text == "else" || token.text == "/if" {
+			return i, token.text, nil
+		}
+
+		if name, ok := strings.CutPrefix(token.text, "#if "); ok {
+			value, _ := lookup(strings.TrimSpace(name), data)
+			cond := value != ""
+			j, term, err := renderBlock(tokens, i+1, data, b, emit && cond)
+			if err != nil {
+				return j, term, err
+			}
+			if term == "else" {
+				j, term, err = renderBlock(tokens, j+1, data, b, emit && !cond)
+				if err != nil {
+					return j, term, err
+				}
+			}
+			if term != "/if" {
+				return j, term, fmt.Errorf("{{#if %s}} is missing a closing {{/if}}", strings.TrimSpace(name))
+			}
+			i = j
+			continue
+		}
+
+		value, err := evaluate(token.text, data)
+		if err != nil {
+			return i, "", err
+		}
+		if emit {
+			b.WriteString(value)
+		}
+	}
+	return i, "", nil
+}
+
+// Resolve a variable without a format, returning false if it doesn't exist
+func lookup(name string, data TemplateData) (string, bool) {
+	switch name {
+	case "title":
+		return data.Title, true
+	case "date", "yesterday", "tomorrow", "time":
+		value, _ := evaluate(name, data)
+		return value, true
+	}
+	value, ok := data.Vars[name]
+	return value, ok
+}
+
+func evaluate(placeholder string, data TemplateData) (string, error) {
+	name, format, hasFormat := strings.Cut(placeholder, ":")
+	name = strings.TrimSpace(name)
+
+	var date time.Time
+	switch name {
+	case "date":
+		date = data.Date
+	case "yesterday":
+		date = data.Date.AddDate(0, 0, -1)
+	case "tomorrow":
+		date = data.Date.AddDate(0, 0, 1)
+	case "time":
+		date = data.Date
+		if !hasFormat {
+			format = "HH:mm"
+		}
+	default:
+		value, ok := lookup(name, data)
+		if !ok {
+			return "", fmt.Errorf("unknown variable {{%s}}", name)
+		}
+		if hasFormat {
+			return "", fmt.Errorf("variable {{%s}} does not accept a format", name)
+		}
+		return value, nil
+	}
+
+	if format == "" {
+		format = "YYYY-MM-DD"
+	}
+	return formatDate(date, format), nil
+}
+
+// Longest tokens first so e.g. MMMM isn't matched as MM twice
+var dateTokens = []struct {
+	token  string
+	layout string
+}{
+	{"YYYY", "2006"},
+	{"YY", "06"},
+	{"MMMM", "January"},
+	{"MMM", "Jan"},
+	{"MM", "01"},
+	{"M", "1"},
+	{"dddd", "Monday"},
+	{"ddd", "Mon"},
+	{"DD", "02"},
+	{"D", "2"},
+	{"HH", "15"},
+	{"hh", "03"},
+	{"h", "3"},
+	{"mm", "04"},
+	{"ss", "05"},
+	{"A", "PM"},
+	{"a", "pm"},
+}
+
+// Format the date using moment style tokens. Each token is formatted individually so literal text is never
+// interpreted as part of a Go layout.
+func formatDate(date time.Time, format string) string {
+	var b strings.Builder
+	for format != "" {
+		if format[0] == '[' {
+			if end := strings.IndexByte(format, ']'); end != -1 {
+				b.WriteString(format[1:end])
+				format = format[end+1:]
+				continue
+			}
+		}
+		if strings.HasPrefix(format, "W") {
+			_, week := date.ISOWeek()
+			b.WriteString(fmt.Sprint(week))
+			format = format[1:]
+			continue
+		}
+		matched := false
+		for _, t := range dateTokens {
+			if strings.HasPrefix(format, t.token) {
+				b.WriteString(date.Format(t.layout))
+				format = format[len(t.token):]
+				matched = true
+				break
+			}
+		}
+		if !matched {
+			b.WriteByte(format[0])
+			format = format[1:]
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,160 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package writer_test
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/notedownorg/notedown/pkg/fileserver/reader"
+	"github.com/notedownorg/notedown/pkg/fileserver/writer"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddDocument_Template(t *testing.T) {
+	date := time.Date(2024, 3, 1, 9, 5, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		template string
+		data     writer.TemplateData
+		metadata reader.Metadata
+		content  []byte
+		want     string
+		wantErr  bool
+	}{
+		{
+			name:     "Variables",
+			template: "# {{title}}\n{{date}} {{ time }}\n[[{{yesterday}}]] [[{{tomorrow}}]]\n",
+			data:     writer.TemplateData{Title: "Title", Date: date},
+			want:     "# Title\n2024-03-01 09:05\n[[2024-02-29]] [[2024-03-02]]\n",
+		},
+		{
+			name:     "Date formats",
+			template: "{{date:dddd D MMMM YYYY}}|{{date:ddd DD/MM/YY}}|{{date:[Week] W}}|{{time:h:mm a}}|{{yesterday:MMM D}}\n",
+			data:     writer.TemplateData{Date: date},
+			want:     "Friday 1 March 2024|Fri 01/03/24|Week 9|9:05 am|Feb 29\n",
+		},
+		{
+			name:     "Title defaults to the file name",
+			template: "# {{title}}\n",
+			data:     writer.TemplateData{Date: date},
+			want:     "# new\n",
+		},
+		{
+			name:     "Custom variables",
+			template: "Owner: {{owner}}\n",
+			data:     writer.TemplateData{Date: date, Vars: map[string]string{"owner": "me"}},
+			want:     "Owner: me\n",
+		},
+		{
+			name:     "Conditionals",
+			template: "{{#if focus}}Focus: {{focus}}\n{{else}}No focus\n{{/if}}{{#if missing}}Hidden\n{{/if}}{{#if title}}{{#if owner}}Nested\n{{/if}}{{/if}}",
+			data:     writer.TemplateData{Title: "Title", Date: date, Vars: map[string]string{"focus": "", "owner": "me"}},
+			want:     "No focus\nNested\n",
+		},
+		{
+			name:     "Frontmatter is rendered and merged with metadata",
+			template: "---\ntype: standup\ndate: {{date}}\n---\n# {{title}}\n",
+			data:     writer.TemplateData{Title: "Standup", Date: date},
+			metadata: reader.Metadata{"type": "daily"},
+			content:  []byte("Appended\n"),
+			want:     "---\ndate: \"2024-03-01\"\ntype: daily\n---\n# Standup\nAppended\n",
+		},
+		{
+			name:     "Unknown variable",
+			template: "{{#if other}}{{unknown}}{{/if}}",
+			data:     writer.TemplateData{Date: date},
+			wantErr:  true,
+		},
+		{
+			name:     "Format on a non date variable",
+			template: "{{title:YYYY}}",
+			data:     writer.TemplateData{Date: date},
+			wantErr:  true,
+		},
+		{
+			name:     "Unclosed conditional",
+			template: "{{#if title}}Title",
+			data:     writer.TemplateData{Date: date},
+			wantErr:  true,
+		},
+		{
+			name:     "Unexpected end of conditional",
+			template: "Title{{/if}}",
+			data:     writer.TemplateData{Date: date},
+			wantErr:  true,
+		},
+		{
+			name:     "Unclosed placeholder",
+			template: "{{title",
+			data:     writer.TemplateData{Date: date},
+			wantErr:  true,
+		},
+	}
+	dir, err := setupTestDir(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := writer.NewClient(dir)
+	for i, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Use a separate template and note for each test to avoid interference
+			template, note := fmt.Sprintf("%d.md", i), filepath.Join(fmt.Sprint(i), "new.md")
+			if err := os.MkdirAll(filepath.Join(dir, "templates"), 0755); err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(filepath.Join(dir, "templates", template), []byte(tt.template), 0644); err != nil {
+				t.Fatal(err)
+			}
+
+			err := client.Add(note, tt.metadata, tt.content, writer.WithTemplate(template, tt.data))
+			if tt.wantErr {
+				assert.Error(t, err)
+				_, err := os.Stat(filepath.Join(dir, note))
+				assert.True(t, os.IsNotExist(err), "expected no file to be written")
+				return
+			}
+			assert.NoError(t, err)
+			contents, err := os.ReadFile(filepath.Join(dir, note))
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, string(contents))
+		})
+	}
+}
+
+func TestAddDocument_TemplateDir(t *testing.T) {
+	dir, err := setupTestDir(t.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(dir, "meta", "templates"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "meta", "templates", "note.md"), []byte("# {{title}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err = writer.NewClient(dir).Add("new.md", nil, nil, writer.WithTemplate("note.md", writer.TemplateData{}))
+	assert.True(t, errors.Is(err, os.ErrNotExist), "expected template to be missing from the default directory, got %v", err)
+
+	assert.NoError(t, writer.NewClient(dir, writer.WithTemplateDir("meta/templates")).Add("new.md", nil, nil, writer.WithTemplate("note.md", writer.TemplateData{})))
+	contents, err := os.ReadFile(filepath.Join(dir, "new.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, "# new\n", string(contents))
+}
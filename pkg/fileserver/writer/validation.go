@@ -15,47 +15,96 @@
 package writer
 
 import (
+	"bytes"
 	"crypto/sha256"
 	"fmt"
-	"os"
 	"strings"
 )
 
-// returns lines, where the frontmatter ends or -1 if there is no frontmatter and an error
-func readAndValidateFile(path string, checksum string) ([]string, int, error) {
-	bytes, err := os.ReadFile(path)
-	if err != nil {
-		return nil, -1, err
-	}
+var bom = []byte{0xEF, 0xBB, 0xBF}
+
+// The lines of a file along with the details required to write it back out in the same format
+type file struct {
+	lines []string
+
+	// Where the frontmatter ends or -1 if there is no frontmatter
+	frontmatter int
+
+	bom          bool
+	newline      string
+	finalNewline bool
+}
 
+// returns the parsed file
+// if the file has been modified since the checksum was taken, the file is returned along with a StaleWriteError
+func validateFile(path string, b []byte, checksum string) (file, error) {
 	// Ensure file hasn't been modified only if a hash is provided
 	if checksum != "" {
 		algo := sha256.New()
-		algo.Write(bytes)
+		algo.Write(b)
 		latest := fmt.Sprintf("%x", algo.Sum(nil))
 		if checksum != latest {
-			return nil, -1, fmt.Errorf("file has been modified since last read, unable to write with stale data wanted: %s got: %s", latest, checksum)
+			return parseFile(b), &StaleWriteError{Path: path, Checksum: checksum, Latest: latest}
 		}
 	}
 
-	res := strings.Split(string(bytes), "\n")
+	return parseFile(b), nil
+}
+
+func parseFile(b []byte) file {
+	res := file{frontmatter: -1, newline: "\n"}
+
+	// The byte order mark isn't content so strip it and remember to put it back on write
+	if bytes.HasPrefix(b, bom) {
+		res.bom = true
+		b = b[len(bom):]
+	}
+
+	// Empty files have no style to preserve so default to terminating lines with a newline
+	res.finalNewline = len(b) == 0 || bytes.HasSuffix(b, []byte("\n"))
+
+	// Use the first line ending to decide on the style for the whole document
+	if i := bytes.IndexByte(b, '\n'); i > 0 && b[i-1] == '\r' {
+		res.newline = "\r\n"
+	}
+
+	res.lines = strings.Split(string(b), "\n")
 
 	// Remove the last line if it's empty to prevent adding additional whitespace
-	if len(res) > 0 && res[len(res)-1] == "" {
-		res = res[:len(res)-1]
+	if len(res.lines) > 0 && res.lines[len(res.lines)-1] == "" {
+		res.lines = res.lines[:len(res.lines)-1]
+	}
+	if res.newline == "\r\n" {
+		for i, line := range res.lines {
+			res.lines[i] = strings.TrimSuffix(line, "\r")
+		}
 	}
 
 	// Check if the file has frontmatter
 	// This is a simple/fast check, but it should be sufficient for this use case
-	frontmatter := -1
-	if len(res) > 0 && strings.HasPrefix(res[0], "---") {
-		for i, line := range res[1:] {
+	if len(res.lines) > 0 && strings.HasPrefix(res.lines[0], "---") {
+		for i, line := range res.lines[1:] {
 			if strings.HasPrefix(line, "---") {
-				frontmatter = i + 2 // 0 -> 1-indexed and after the current line
+				res.frontmatter = i + 2 // 0 -> 1-indexed and after the current line
 				break
 			}
 		}
 	}
 
-	return res, frontmatter, nil
+	return res
+}
+
+// Render the file using its original byte order mark, newline style and final newline
+func (f file) bytes() []byte {
+	var b bytes.Buffer
+	if f.bom {
+		b.Write(bom)
+	}
+	for i, line := range f.lines {
+		b.WriteString(line)
+		if i < len(f.lines)-1 || f.finalNewline {
+			b.WriteString(f.newline)
+		}
+	}
+	return b.Bytes()
 }
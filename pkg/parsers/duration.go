@@ -14,7 +14,14 @@
 
 package parsers
 
-import "github.com/a-h/parse"
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/a-h/parse"
+)
 
 var (
 	// More specific first.
@@ -23,3 +30,57 @@ var (
 	Month = parse.Any(parse.String("months"), parse.String("month"))
 	Year  = parse.Any(parse.String("years"), parse.String("year"))
 )
+
+// Duration parses an amount of time in hours and/or minutes e.g. 1h30m, 45m or 2h.
+// Like relative dates, the duration must be followed by whitespace or the end of the input.
+var Duration = parse.Func(func(in *parse.Input) (time.Duration, bool, error) {
+	start := in.Index()
+	var res time.Duration
+	var found bool
+	for _, unit := range []struct {
+		suffix rune
+		size   time.Duration
+	}{{'h', time.Hour}, {'m', time.Minute}} {
+		before := in.Index()
+		n, ok, err := number.Parse(in)
+		if err != nil {
+			return 0, false, err
+		}
+		if !ok {
+			continue
+		}
+		if _, ok, err := parse.Rune(unit.suffix).Parse(in); err != nil || !ok {
+			in.Seek(before)
+			if err != nil {
+				return 0, false, err
+			}
+			continue
+		}
+		// An amount too large to be represented isn't a duration, it shouldn't stop the rest of the input being parsed
+		amount, err := strconv.ParseInt(n, 10, 64)
+		if err != nil || amount > int64((math.MaxInt64-res)/unit.size) {
+			in.Seek(start)
+			return 0, false, nil
+		}
+		res += time.Duration(amount) * unit.size
+		found = true
+	}
+	if !found || !atWordBoundary(in) {
+		in.Seek(start)
+		return 0, false, nil
+	}
+	return res, true, nil
+})
+
+// FormatDuration writes the duration in the format read by Duration, rounded down to the minute e.g. 1h30m
+func FormatDuration(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+	hours, minutes := int(d/time.Hour), int(d%time.Hour/time.Minute)
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	}
+	return fmt.Sprintf("%dh%dm", hours, minutes)
+}
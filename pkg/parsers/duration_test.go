@@ -0,0 +1,68 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parsers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/a-h/parse"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/notedownorg/notedown/pkg/parsers"
+)
+
+func TestDuration(t *testing.T) {
+	tests := []struct {
+		input     string
+		want      time.Duration
+		formatted string
+		remaining string
+		notFound  bool
+	}{
+		{input: "45m", want: 45 * time.Minute, formatted: "45m"},
+		{input: "2h", want: 2 * time.Hour, formatted: "2h"},
+		{input: "1h30m", want: 90 * time.Minute, formatted: "1h30m"},
+		{input: "90m", want: 90 * time.Minute, formatted: "1h30m"},
+		{input: "0m", want: 0, formatted: "0m"},
+		{input: "1h30m p:1", want: 90 * time.Minute, formatted: "1h30m", remaining: " p:1"},
+		{input: "30m1h", notFound: true},
+		{input: "45", notFound: true},
+		{input: "45min", notFound: true},
+		{input: "1d", notFound: true},
+		{input: "h", notFound: true},
+		{input: "99999999999999999999h", notFound: true},
+		{input: "2562048h", notFound: true},
+		{input: "2562047h48m", notFound: true},
+		{input: "2562047h47m", want: 2562047*time.Hour + 47*time.Minute, formatted: "2562047h47m"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			in := parse.NewInput(test.input)
+			got, ok, err := parsers.Duration.Parse(in)
+			assert.NoError(t, err)
+			if test.notFound {
+				assert.False(t, ok)
+				assert.Equal(t, 0, in.Index())
+				return
+			}
+			assert.True(t, ok)
+			assert.Equal(t, test.want, got)
+			assert.Equal(t, test.formatted, parsers.FormatDuration(got))
+			remaining, _ := in.Peek(-1)
+			assert.Equal(t, test.remaining, remaining)
+		})
+	}
+}
@@ -0,0 +1,183 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parsers
+
+import (
+	"math"
+	"strconv"
+	"time"
+	"unicode"
+
+	"github.com/a-h/parse"
+)
+
+// RelativeDate parses a date relative to the given time, the result is the date (at midnight UTC) without a time.
+//
+//   - today, tomorrow or yesterday
+//   - a day of the week e.g. fri (the next friday, today if it is friday) or next fri (the next friday after today)
+//   - an offset e.g. +3d, +2w, +1m or +1y
+//   - in <number> <days/weeks/months/years> e.g. in 10 days
+//   - end of week (sunday), end of month or end of year
+//
+// The date must be followed by whitespace or the end of the input so e.g. "today's" isn't a date.
+var RelativeDate = func(relativeTo time.Time) parse.Parser[time.Time] {
+	return parse.Func(func(in *parse.Input) (time.Time, bool, error) {
+		start := in.Index()
+		today := time.Date(relativeTo.Year(), relativeTo.Month(), relativeTo.Day(), 0, 0, 0, 0, time.UTC)
+		for _, parser := range []parse.Parser[time.Time]{namedDay(today), endOf(today), offset(today), inDuration(today), weekday(today)} {
+			date, ok, err := parser.Parse(in)
+			if err != nil {
+				return time.Time{}, false, err
+			}
+			if ok && atWordBoundary(in) {
+				return date, true, nil
+			}
+			in.Seek(start)
+		}
+		return time.Time{}, false, nil
+	})
+}
+
+func atWordBoundary(in *parse.Input) bool {
+	next, ok := in.Peek(1)
+	return !ok || unicode.IsSpace(rune(next[0]))
+}
+
+func namedDay(today time.Time) parse.Parser[time.Time] {
+	return parse.Func(func(in *parse.Input) (time.Time, bool, error) {
+		name, ok, err := parse.Any(parse.String("today"), parse.String("tomorrow"), parse.String("yesterday")).Parse(in)
+		if err != nil || !ok {
+			return time.Time{}, false, err
+		}
+		switch name {
+		case "tomorrow":
+			return today.AddDate(0, 0, 1), true, nil
+		case "yesterday":
+			return today.AddDate(0, 0, -1), true, nil
+		}
+		return today, true, nil
+	})
+}
+
+func endOf(today time.Time) parse.Parser[time.Time] {
+	return parse.Func(func(in *parse.Input) (time.Time, bool, error) {
+		if _, ok, err := parse.String("end of ").Parse(in); err != nil || !ok {
+			return time.Time{}, false, err
+		}
+		unit, ok, err := parse.Any(parse.String("week"), parse.String("month"), parse.String("year")).Parse(in)
+		if err != nil || !ok {
+			return time.Time{}, false, err
+		}
+		switch unit {
+		case "week":
+			return today.AddDate(0, 0, (7-int(today.Weekday()))%7), true, nil
+		case "month":
+			return time.Date(today.Year(), today.Month()+1, 0, 0, 0, 0, 0, time.UTC), true, nil
+		}
+		return time.Date(today.Year(), time.December, 31, 0, 0, 0, 0, time.UTC), true, nil
+	})
+}
+
+var number = parse.StringFrom(parse.AtLeast(1, parse.ZeroToNine))
+
+// +3d, +2w, +1m or +1y
+func offset(today time.Time) parse.Parser[time.Time] {
+	return parse.Func(func(in *parse.Input) (time.Time, bool, error) {
+		if _, ok, err := parse.Rune('+').Parse(in); err != nil || !ok {
+			return time.Time{}, false, err
+		}
+		n, ok, err := number.Parse(in)
+		if err != nil || !ok {
+			return time.Time{}, false, err
+		}
+		unit, ok, err := parse.RuneIn("dwmy").Parse(in)
+		if err != nil || !ok {
+			return time.Time{}, false, err
+		}
+		return add(today, n, unit)
+	})
+}
+
+// in 10 days, in 2 weeks, in 1 month or in 1 year
+func inDuration(today time.Time) parse.Parser[time.Time] {
+	return parse.Func(func(in *parse.Input) (time.Time, bool, error) {
+		if _, ok, err := parse.String("in ").Parse(in); err != nil || !ok {
+			return time.Time{}, false, err
+		}
+		n, ok, err := number.Parse(in)
+		if err != nil || !ok {
+			return time.Time{}, false, err
+		}
+		if _, ok, err := parse.Rune(' ').Parse(in); err != nil || !ok {
+			return time.Time{}, false, err
+		}
+		unit, ok, err := parse.Any(Day, Week, Month, Year).Parse(in)
+		if err != nil || !ok {
+			return time.Time{}, false, err
+		}
+		return add(today, n, unit[:1])
+	})
+}
+
+func add(today time.Time, n string, unit string) (time.Time, bool, error) {
+	// A count too large to be represented isn't a relative date, it shouldn't stop the rest of the input being parsed
+	count, err := strconv.Atoi(n)
+	if err != nil || (unit == "w" && count > math.MaxInt/7) {
+		return time.Time{}, false, nil
+	}
+	switch unit {
+	case "d":
+		return today.AddDate(0, 0, count), true, nil
+	case "w":
+		return today.AddDate(0, 0, 7*count), true, nil
+	case "m":
+		return today.AddDate(0, count, 0), true, nil
+	}
+	return today.AddDate(count, 0, 0), true, nil
+}
+
+// fri is the next friday including today, next fri is the next friday after today
+func weekday(today time.Time) parse.Parser[time.Time] {
+	return parse.Func(func(in *parse.Input) (time.Time, bool, error) {
+		_, next, err := parse.String("next ").Parse(in)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		day, ok, err := DayOfWeek.Parse(in)
+		if err != nil || !ok {
+			return time.Time{}, false, err
+		}
+		days := (int(day) - int(today.Weekday()) + 7) % 7
+		if next && days == 0 {
+			days = 7
+		}
+		return today.AddDate(0, 0, days), true, nil
+	})
+}
+
+// Date parses either an absolute DateTime or a RelativeDate, relative dates are resolved to an absolute date
+var Date = func(relativeTo time.Time) parse.Parser[Timestamp] {
+	return parse.Func(func(in *parse.Input) (Timestamp, bool, error) {
+		absolute, ok, err := DateTime.Parse(in)
+		if err != nil || ok {
+			return absolute, ok, err
+		}
+		relative, ok, err := RelativeDate(relativeTo).Parse(in)
+		if err != nil || !ok {
+			return Timestamp{}, false, err
+		}
+		return Timestamp{Time: relative, Layout: DateLayout}, true, nil
+	})
+}
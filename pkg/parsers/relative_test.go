@@ -0,0 +1,82 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parsers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/a-h/parse"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/notedownorg/notedown/pkg/parsers"
+)
+
+func TestRelativeDate(t *testing.T) {
+	// Wednesday, late in the day and outside of UTC to ensure only the date is used
+	relativeTo := time.Date(2024, time.November, 6, 23, 30, 0, 0, time.FixedZone("", -5*3600))
+	day := func(month time.Month, d int) time.Time { return time.Date(2024, month, d, 0, 0, 0, 0, time.UTC) }
+
+	tests := []struct {
+		input     string
+		want      time.Time
+		remaining string
+		notFound  bool
+	}{
+		{input: "today", want: day(time.November, 6)},
+		{input: "tomorrow", want: day(time.November, 7)},
+		{input: "yesterday", want: day(time.November, 5)},
+		{input: "fri", want: day(time.November, 8)},
+		{input: "wednesday", want: day(time.November, 6)},
+		{input: "next wed", want: day(time.November, 13)},
+		{input: "next monday", want: day(time.November, 11)},
+		{input: "mon", want: day(time.November, 11)},
+		{input: "+3d", want: day(time.November, 9)},
+		{input: "+2w", want: day(time.November, 20)},
+		{input: "+1m", want: day(time.December, 6)},
+		{input: "+1y", want: time.Date(2025, time.November, 6, 0, 0, 0, 0, time.UTC)},
+		{input: "in 10 days", want: day(time.November, 16)},
+		{input: "in 1 week", want: day(time.November, 13)},
+		{input: "in 2 months", want: time.Date(2025, time.January, 6, 0, 0, 0, 0, time.UTC)},
+		{input: "end of week", want: day(time.November, 10)},
+		{input: "end of month", want: day(time.November, 30)},
+		{input: "end of year", want: day(time.December, 31)},
+		{input: "today p:1", want: day(time.November, 6), remaining: " p:1"},
+		{input: "today's", notFound: true},
+		{input: "friend", notFound: true},
+		{input: "+3", notFound: true},
+		{input: "in 10", notFound: true},
+		{input: "end of days", notFound: true},
+		{input: "+99999999999999999999d", notFound: true},
+		{input: "in 99999999999999999999 years", notFound: true},
+		{input: "+9223372036854775807w", notFound: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			input := parse.NewInput(tt.input)
+			got, found, err := parsers.RelativeDate(relativeTo).Parse(input)
+			assert.NoError(t, err)
+			remaining, _ := input.Peek(-1)
+			if tt.notFound {
+				assert.False(t, found, "expected not found, got %v", got)
+				assert.Equal(t, tt.input, remaining, "expected no input to be consumed")
+				return
+			}
+			assert.True(t, found)
+			assert.Equal(t, tt.want, got)
+			assert.Equal(t, tt.remaining, remaining)
+		})
+	}
+}
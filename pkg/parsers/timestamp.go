@@ -0,0 +1,161 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parsers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/a-h/parse"
+)
+
+const DateLayout = "2006-01-02"
+
+// Timestamp is a date with an optional time of day and zone offset. Layout records how it was written so it can be
+// written back with the same precision. Timestamps without an offset are floating (i.e. wall clock) times and are
+// represented in UTC.
+type Timestamp struct {
+	time.Time
+	Layout string
+}
+
+// Create a timestamp using the most compact layout that represents the time. Times at midnight UTC are dates,
+// other times include the time of day (to the second if required) and times outside of UTC include their offset.
+func NewTimestamp(t time.Time) Timestamp {
+	if t.Location() == time.UTC && t.Hour() == 0 && t.Minute() == 0 && t.Second() == 0 && t.Nanosecond() == 0 {
+		return Timestamp{Time: t, Layout: DateLayout}
+	}
+	layout := DateLayout + "T15:04"
+	if t.Second() != 0 {
+		layout += ":05"
+	}
+	if t.Location() != time.UTC {
+		layout += "Z07:00"
+	}
+	return Timestamp{Time: t, Layout: layout}
+}
+
+func (t Timestamp) String() string {
+	return t.Format(t.Layout)
+}
+
+// Whether the timestamp includes a time of day rather than just a date
+func (t Timestamp) HasTime() bool {
+	return t.Layout != DateLayout
+}
+
+// The last instant the timestamp covers, a date covers the whole day
+func (t Timestamp) End() time.Time {
+	if t.HasTime() {
+		return t.Time
+	}
+	return t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+}
+
+// Compare timestamps of mixed precision, dates are ordered after times on the same day as they cover the whole day
+func (t Timestamp) Compare(u Timestamp) int {
+	if c := t.End().Compare(u.End()); c != 0 {
+		return c
+	}
+	return t.Time.Compare(u.Time)
+}
+
+var twoDigits = parse.StringFrom(parse.Times(2, parse.ZeroToNine))
+
+// HH:mm with optional :ss
+var timeOfDay = parse.Func(func(in *parse.Input) (layout string, ok bool, err error) {
+	_, ok, err = parse.StringFrom(twoDigits, parse.Rune(':'), twoDigits).Parse(in)
+	if err != nil || !ok {
+		return "", false, err
+	}
+	layout = "15:04"
+	_, ok, err = parse.StringFrom(parse.Rune(':'), twoDigits).Parse(in)
+	if err != nil {
+		return "", false, err
+	}
+	if ok {
+		layout += ":05"
+	}
+	return layout, true, nil
+})
+
+// Z, +01:00 or +0100
+var zoneOffset = parse.Func(func(in *parse.Input) (layout string, ok bool, err error) {
+	if _, ok, err := parse.Rune('Z').Parse(in); err != nil || ok {
+		return "Z07:00", ok, err
+	}
+	start := in.Index()
+	if _, ok, err := parse.RuneIn("+-").Parse(in); err != nil || !ok {
+		return "", false, err
+	}
+	if _, ok, err := twoDigits.Parse(in); err != nil || !ok {
+		in.Seek(start)
+		return "", false, err
+	}
+	colon, _, err := parse.Rune(':').Parse(in)
+	if err != nil {
+		return "", false, err
+	}
+	if _, ok, err := twoDigits.Parse(in); err != nil || !ok {
+		in.Seek(start)
+		return "", false, err
+	}
+	if colon != "" {
+		return "Z07:00", true, nil
+	}
+	return "-0700", true, nil
+})
+
+// A date (YYYY-MM-DD) optionally followed by a time of day separated by a T or space (HH:mm or HH:mm:ss) and an
+// optional zone offset (Z, +01:00 or +0100).
+var DateTime = parse.Func(func(in *parse.Input) (match Timestamp, ok bool, err error) {
+	start := in.Index()
+	if _, ok, err := YearMonthDay.Parse(in); err != nil || !ok {
+		return Timestamp{}, false, err
+	}
+	layout := DateLayout
+
+	// The time is optional so if it isn't all there leave the input after the date
+	afterDate := in.Index()
+	separator, ok, err := parse.RuneIn("T ").Parse(in)
+	if err != nil {
+		return Timestamp{}, false, err
+	}
+	if ok {
+		clock, ok, err := timeOfDay.Parse(in)
+		if err != nil {
+			return Timestamp{}, false, err
+		}
+		if ok {
+			layout += separator + clock
+			zone, _, err := zoneOffset.Parse(in)
+			if err != nil {
+				return Timestamp{}, false, err
+			}
+			layout += zone
+		} else {
+			in.Seek(afterDate)
+		}
+	}
+
+	end := in.Index()
+	in.Seek(start)
+	s, _ := in.Take(end - start)
+	t, err := time.Parse(layout, s)
+	if err != nil {
+		return Timestamp{}, false, fmt.Errorf("failed to parse date time: %w", err)
+	}
+	return Timestamp{Time: t, Layout: layout}, true, nil
+})
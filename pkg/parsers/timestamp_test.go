@@ -0,0 +1,129 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package parsers_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/a-h/parse"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/notedownorg/notedown/pkg/parsers"
+)
+
+func TestDateTime(t *testing.T) {
+	tests := []struct {
+		input     string
+		want      time.Time
+		layout    string
+		remaining string
+		notFound  bool
+	}{
+		{
+			input:  "2021-01-01",
+			want:   time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC),
+			layout: "2006-01-02",
+		},
+		{
+			input:  "2021-01-01T14:00",
+			want:   time.Date(2021, time.January, 1, 14, 0, 0, 0, time.UTC),
+			layout: "2006-01-02T15:04",
+		},
+		{
+			input:  "2021-01-01 14:00:30",
+			want:   time.Date(2021, time.January, 1, 14, 0, 30, 0, time.UTC),
+			layout: "2006-01-02 15:04:05",
+		},
+		{
+			input:  "2021-01-01T14:00Z",
+			want:   time.Date(2021, time.January, 1, 14, 0, 0, 0, time.UTC),
+			layout: "2006-01-02T15:04Z07:00",
+		},
+		{
+			input:  "2021-01-01T14:00+01:00",
+			want:   time.Date(2021, time.January, 1, 13, 0, 0, 0, time.UTC),
+			layout: "2006-01-02T15:04Z07:00",
+		},
+		{
+			input:  "2021-01-01 14:00-0500",
+			want:   time.Date(2021, time.January, 1, 19, 0, 0, 0, time.UTC),
+			layout: "2006-01-02 15:04-0700",
+		},
+		{
+			input:     "2021-01-01 text",
+			want:      time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC),
+			layout:    "2006-01-02",
+			remaining: " text",
+		},
+		{
+			input:     "2021-01-01T14:00:xx",
+			want:      time.Date(2021, time.January, 1, 14, 0, 0, 0, time.UTC),
+			layout:    "2006-01-02T15:04",
+			remaining: ":xx",
+		},
+		{
+			input:     "2021-01-01T14:00+1",
+			want:      time.Date(2021, time.January, 1, 14, 0, 0, 0, time.UTC),
+			layout:    "2006-01-02T15:04",
+			remaining: "+1",
+		},
+		{
+			input:    "2021-01-01T25:00",
+			notFound: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			input := parse.NewInput(tt.input)
+			got, found, _ := parsers.DateTime.Parse(input)
+			if tt.notFound {
+				if found {
+					t.Fatalf("expected not found, got %v", got)
+				}
+				return
+			}
+			if !found {
+				t.Fatalf("expected found, got not found")
+			}
+			assert.True(t, tt.want.Equal(got.Time), "expected %v, got %v", tt.want, got.Time)
+			assert.Equal(t, tt.layout, got.Layout)
+			remaining, _ := input.Peek(-1)
+			assert.Equal(t, tt.remaining, remaining)
+
+			// Written back with the same precision
+			assert.Equal(t, tt.input[:len(tt.input)-len(tt.remaining)], got.String())
+		})
+	}
+}
+
+func TestTimestamp(t *testing.T) {
+	date := parsers.NewTimestamp(time.Date(2021, time.January, 1, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, "2021-01-01", date.String())
+	assert.False(t, date.HasTime())
+
+	afternoon := parsers.NewTimestamp(time.Date(2021, time.January, 1, 14, 0, 0, 0, time.UTC))
+	assert.Equal(t, "2021-01-01T14:00", afternoon.String())
+	assert.True(t, afternoon.HasTime())
+
+	zoned := parsers.NewTimestamp(time.Date(2021, time.January, 1, 14, 0, 5, 0, time.FixedZone("", 3600)))
+	assert.Equal(t, "2021-01-01T14:00:05+01:00", zoned.String())
+
+	// A date covers the whole day so is ordered after times on the same day
+	assert.Equal(t, -1, afternoon.Compare(date))
+	assert.Equal(t, 1, date.Compare(afternoon))
+	assert.Equal(t, 0, date.Compare(date))
+	assert.Equal(t, time.Date(2021, time.January, 1, 23, 59, 59, 999999999, time.UTC), date.End())
+}
@@ -19,6 +19,7 @@ import (
 	"time"
 
 	"github.com/notedownorg/notedown/pkg/fileserver/reader"
+	"github.com/notedownorg/notedown/pkg/fileserver/writer"
 	"github.com/notedownorg/notedown/pkg/providers/pkg/collections"
 	"github.com/notedownorg/notedown/pkg/providers/pkg/traits"
 )
@@ -28,14 +29,15 @@ type watcher = traits.Watcher
 type publisher = traits.Publisher[Event]
 
 type DocumentWriter interface {
-	Add(path string, metadata reader.Metadata, content []byte) error
+	Add(path string, metadata reader.Metadata, content []byte, opts ...writer.AddOption) error
 }
 
 type Client struct {
 	*watcher
 	*publisher
-	writer DocumentWriter
-	dir    string
+	writer   DocumentWriter
+	dir      string
+	template string
 
 	// notes maps between file paths to notes it should ONLY be updated in response
 	// to events from the docuuments client and should otherwise be read-only.
@@ -52,6 +54,13 @@ func WithInitialLoadWaiter(tick time.Duration) clientOptions {
 	}
 }
 
+// Create new daily notes from the named template, see writer.TemplateData for the available variables
+func WithTemplate(name string) clientOptions {
+	return func(client *Client) {
+		client.template = name
+	}
+}
+
 func NewClient(writer DocumentWriter, feed <-chan reader.Event, opts ...clientOptions) *Client {
 	client := &Client{
 		notes:  make(map[string]Daily),
@@ -19,9 +19,11 @@ import (
 	"path/filepath"
 	"strings"
 	"time"
+
+	"github.com/notedownorg/notedown/pkg/providers/pkg/doctypes"
 )
 
-const MetadataKey = "daily"
+const MetadataKey = doctypes.Daily
 
 type Identifier struct {
 	path    string
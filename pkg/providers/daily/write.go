@@ -20,10 +20,42 @@ import (
 	"time"
 
 	"github.com/notedownorg/notedown/pkg/fileserver/reader"
+	"github.com/notedownorg/notedown/pkg/fileserver/writer"
 )
 
+type writeConfig struct {
+	writer   DocumentWriter
+	template string
+}
+
+type writeOptions func(*writeConfig)
+
+// Record the changes in the dry run rather than writing them to disk
+func WithDryRun(dryRun *writer.DryRun) writeOptions {
+	return func(config *writeConfig) {
+		config.writer = dryRun
+	}
+}
+
+// Create the daily note from the named template instead of the client's (see WithTemplate), an empty name creates it
+// without a template
+func WithTemplateName(name string) writeOptions {
+	return func(config *writeConfig) {
+		config.template = name
+	}
+}
+
+func (c *Client) writeConfig(opts []writeOptions) writeConfig {
+	config := writeConfig{writer: c.writer, template: c.template}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
 // Set wait to 0 to not wait for the file to appear in the cache
 // Otherwise ensure will error if the file does not appear in the cache within the wait duration
+// Waiting returns as soon as the note's event has been handled, pair with writer.WithReader to avoid waiting on the file watcher
 func (c *Client) Ensure(date time.Time, wait time.Duration) (Daily, bool, error) {
 	// O(n) but probably fine
 	// Unless humans achieve immortality or pre-emptively generate daily notes assuming they will live forever...
@@ -40,21 +72,23 @@ func (c *Client) Ensure(date time.Time, wait time.Duration) (Daily, bool, error)
 	}
 
 	// If wait is set we wait for the file to appear in our cache before returning
-	start := time.Now()
-	for {
-		if time.Since(start) > wait {
-			return Daily{}, false, fmt.Errorf("timed out waiting for daily note to appear")
-		}
+	visible := c.watcher.WaitFor(wait, func() bool {
 		matches = c.ListDailyNotes(FetchAllNotes(), WithFilters(FilterByDate(&date, &date)))
-		if len(matches) > 0 {
-			return matches[0], true, nil
-		}
-		time.Sleep(100 * time.Millisecond)
+		return len(matches) > 0
+	})
+	if !visible {
+		return Daily{}, false, fmt.Errorf("timed out waiting for daily note to appear")
 	}
+	return matches[0], true, nil
 }
 
-func (c *Client) Create(date time.Time) error {
+func (c *Client) Create(date time.Time, opts ...writeOptions) error {
+	config := c.writeConfig(opts)
 	name := date.Format("2006-01-02")
 	path := filepath.Join("daily", fmt.Sprintf("%s.md", name))
-	return c.writer.Add(path, reader.Metadata{reader.MetadataTypeKey: MetadataKey}, []byte{})
+	addOpts := []writer.AddOption{writer.WithDescription(fmt.Sprintf("daily: create %s", name))}
+	if config.template != "" {
+		addOpts = append(addOpts, writer.WithTemplate(config.template, writer.TemplateData{Title: name, Date: date}))
+	}
+	return config.writer.Add(path, reader.Metadata{reader.MetadataTypeKey: MetadataKey}, []byte{}, addOpts...)
 }
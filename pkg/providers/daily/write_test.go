@@ -15,6 +15,8 @@
 package daily_test
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -58,3 +60,55 @@ func TestWrite(t *testing.T) {
 
 	assert.NoError(t, client.Create(date))
 }
+
+func TestWrite_Template(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	template := "---\ntags: [standup]\n---\n# {{date:dddd D MMMM}}\n\n## Yesterday\n\nSee [[{{yesterday}}]]\n\n## Today\n"
+	if err := os.WriteFile(filepath.Join(dir, "templates", "standup.md"), []byte(template), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	feed := make(chan reader.Event)
+	go func() { feed <- reader.Event{Op: reader.SubscriberLoadComplete} }()
+	client := daily.NewClient(writer.NewClient(dir), feed, daily.WithTemplate("standup"), daily.WithInitialLoadWaiter(100*time.Millisecond))
+
+	assert.NoError(t, client.Create(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)))
+	contents, err := os.ReadFile(filepath.Join(dir, "daily", "2024-01-01.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, "---\ntags:\n- standup\ntype: daily\n---\n# Monday 1 January\n\n## Yesterday\n\nSee [[2023-12-31]]\n\n## Today\n", string(contents))
+}
+
+func TestWrite_TemplateName(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "templates"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, template := range map[string]string{"standup": "# Standup {{title}}\n", "retro": "# Retro {{title}}\n"} {
+		if err := os.WriteFile(filepath.Join(dir, "templates", name+".md"), []byte(template), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	feed := make(chan reader.Event)
+	go func() { feed <- reader.Event{Op: reader.SubscriberLoadComplete} }()
+	client := daily.NewClient(writer.NewClient(dir), feed, daily.WithTemplate("standup"), daily.WithInitialLoadWaiter(100*time.Millisecond))
+
+	read := func(name string) string {
+		contents, err := os.ReadFile(filepath.Join(dir, "daily", name))
+		assert.NoError(t, err)
+		return string(contents)
+	}
+
+	// The template chosen when creating the note overrides the client's
+	assert.NoError(t, client.Create(time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC), daily.WithTemplateName("retro")))
+	assert.Contains(t, read("2024-01-05.md"), "# Retro 2024-01-05\n")
+
+	assert.NoError(t, client.Create(time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC)))
+	assert.Contains(t, read("2024-01-08.md"), "# Standup 2024-01-08\n")
+
+	assert.NoError(t, client.Create(time.Date(2024, 1, 9, 0, 0, 0, 0, time.UTC), daily.WithTemplateName("")))
+	assert.NotContains(t, read("2024-01-09.md"), "Standup")
+}
@@ -15,3 +15,13 @@
 package collections
 
 type ListOption[T any] func([]T) []T
+
+// Keep the first n items, apply after sorting so the same items are kept each time
+func Limit[T any](n int) ListOption[T] {
+	return func(ts []T) []T {
+		if len(ts) > n {
+			return ts[:n]
+		}
+		return ts
+	}
+}
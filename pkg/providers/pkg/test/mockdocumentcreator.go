@@ -29,7 +29,7 @@ type MockDocumentCreator struct {
 	Validators []AddValidator
 }
 
-func (m *MockDocumentCreator) Add(path string, metadata reader.Metadata, content []byte) error {
+func (m *MockDocumentCreator) Add(path string, metadata reader.Metadata, content []byte, opts ...writer.AddOption) error {
 	return m.validate(writer.Document{Path: path}, metadata, content)
 }
 
@@ -15,6 +15,7 @@
 package traits
 
 import (
+	"sync"
 	"time"
 
 	"github.com/notedownorg/notedown/pkg/fileserver/reader"
@@ -41,6 +42,13 @@ type Watcher struct {
 	onDelete func(reader.Event)
 
 	InitialLoadComplete bool
+
+	// Track the events that have been handled so callers can wait for changes to become visible
+	mutex    sync.Mutex
+	changed  *sync.Cond
+	handled  uint64
+	counts   map[string]uint64
+	versions map[string]string
 }
 
 func NewWatcher(feed <-chan reader.Event, onLoad, onChange, onDelete EventHandler) *Watcher {
@@ -50,7 +58,10 @@ func NewWatcher(feed <-chan reader.Event, onLoad, onChange, onDelete EventHandle
 		onChange:            onChange,
 		onDelete:            onDelete,
 		InitialLoadComplete: false,
+		counts:              make(map[string]uint64),
+		versions:            make(map[string]string),
 	}
+	s.changed = sync.NewCond(&s.mutex)
 	go s.start()
 	return s
 }
@@ -69,6 +80,72 @@ func (s *Watcher) start() {
 			case reader.SubscriberLoadComplete:
 				s.InitialLoadComplete = true
 			}
+			s.record(event)
+		}
+	}
+}
+
+// Record the event as handled and wake anyone waiting on a change
+func (s *Watcher) record(event reader.Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if event.Key != "" {
+		s.counts[event.Key]++
+		if event.Op == reader.Delete {
+			delete(s.versions, event.Key)
+		} else {
+			s.versions[event.Key] = event.Document.Checksum
+		}
+	}
+	s.handled++
+	s.changed.Broadcast()
+}
+
+// Handled returns the number of events that have been handled for the document.
+// Comparing the count before and after a write allows callers to tell when the write has been seen.
+func (s *Watcher) Handled(key string) uint64 {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.counts[key]
+}
+
+// Version returns the checksum of the latest version of the document that has been handled, empty if there isn't one.
+// Comparing it to the checksum returned by a write allows callers to tell when that exact write has been seen.
+func (s *Watcher) Version(key string) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.versions[key]
+}
+
+// WaitFor blocks until cond returns true or the timeout expires, returning the final result of cond.
+// The condition is checked once up front and then again each time an event has been handled.
+func (s *Watcher) WaitFor(timeout time.Duration, cond func() bool) bool {
+	expired := false
+	timer := time.AfterFunc(timeout, func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		expired = true
+		s.changed.Broadcast()
+	})
+	defer timer.Stop()
+
+	for {
+		// cond is called without holding the lock as it will likely read state that is updated by the event handlers
+		s.mutex.Lock()
+		handled := s.handled
+		s.mutex.Unlock()
+		if cond() {
+			return true
+		}
+
+		s.mutex.Lock()
+		for s.handled == handled && !expired {
+			s.changed.Wait()
+		}
+		done := expired
+		s.mutex.Unlock()
+		if done {
+			return cond()
 		}
 	}
 }
@@ -32,6 +32,12 @@ type DocumentUpdater interface {
 	UpdateContent(doc writer.Document, mutations ...writer.LineMutation) error
 }
 
+// Updaters that can report the checksum of the content they wrote (e.g. writer.Client) allow WithWait to wait for that
+// exact version to be handled
+type VersionedDocumentUpdater interface {
+	UpdateContentVersion(doc writer.Document, mutations ...writer.LineMutation) (string, error)
+}
+
 type Client struct {
 	*watcher
 	*publisher
@@ -41,6 +47,17 @@ type Client struct {
 	// to events from the docuuments client and should otherwise be read-only.
 	tasks      map[string]map[int]Task
 	tasksMutex sync.RWMutex
+
+	// ids indexes tasks across the workspace by their ID, it is guarded by tasksMutex and kept in sync with tasks
+	ids map[string][]Identifier
+
+	generateIDs bool
+
+	// The statuses tasks are parsed with
+	statuses *Statuses
+
+	// How often to check whether the initial load is complete when NewClient waits for it, 0 means don't wait
+	loadTick time.Duration
 }
 
 type clientOptions func(*Client)
@@ -48,23 +65,43 @@ type clientOptions func(*Client)
 // Inform NewClient to wait for the initial load to complete before returning
 func WithInitialLoadWaiter(tick time.Duration) clientOptions {
 	return func(client *Client) {
-		traits.WithInitialLoadWaiter(client.watcher)(tick)
+		client.loadTick = tick
+	}
+}
+
+// Give tasks created without an ID a randomly generated one that isn't used elsewhere in the workspace
+func WithGeneratedIDs() clientOptions {
+	return func(client *Client) {
+		client.generateIDs = true
+	}
+}
+
+// Parse tasks with the given statuses rather than the defaults, see LoadStatuses
+func WithStatuses(statuses *Statuses) clientOptions {
+	return func(client *Client) {
+		client.statuses = statuses
 	}
 }
 
 func NewClient(writer DocumentUpdater, feed <-chan reader.Event, opts ...clientOptions) *Client {
 	client := &Client{
-		tasks:  make(map[string]map[int]Task),
-		writer: writer,
+		tasks:    make(map[string]map[int]Task),
+		ids:      make(map[string][]Identifier),
+		writer:   writer,
+		statuses: defaultStatuses,
 	}
 
-	client.publisher = traits.NewPublisher[Event]()
-	client.watcher = traits.NewWatcher(feed, onLoad(client), onChange(client), onDelete(client))
-
+	// Options are applied before the watcher starts so they're in place for the initial load
 	for _, opt := range opts {
 		opt(client)
 	}
 
+	client.publisher = traits.NewPublisher[Event]()
+	client.watcher = traits.NewWatcher(feed, onLoad(client), onChange(client), onDelete(client))
+	if client.loadTick > 0 {
+		traits.WithInitialLoadWaiter(client.watcher)(client.loadTick)
+	}
+
 	return client
 }
 
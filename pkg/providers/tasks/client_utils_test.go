@@ -15,9 +15,14 @@
 package tasks_test
 
 import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
 	"time"
 
 	"github.com/notedownorg/notedown/pkg/fileserver/reader"
+	"github.com/notedownorg/notedown/pkg/fileserver/writer"
 	"github.com/notedownorg/notedown/pkg/providers/pkg/test"
 	"github.com/notedownorg/notedown/pkg/providers/tasks"
 )
@@ -38,6 +43,27 @@ func buildClient(events []reader.Event, validators ...test.ContentUpdateValidato
 	return client, feed
 }
 
+// A validator that applies the mutations with a real writer to a document that now contains the given lines, recording
+// the result in written. The checksums in the test events never match real content so the document is always stale.
+func staleWriter(t *testing.T, lines []string, written *[]string) test.ContentUpdateValidator {
+	return func(doc writer.Document, mutations ...writer.LineMutation) error {
+		dir := t.TempDir()
+		path := filepath.Join(dir, doc.Path)
+		if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if err := writer.NewClient(dir).UpdateContent(doc, mutations...); err != nil {
+			return err
+		}
+		contents, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		*written = strings.Split(strings.TrimSuffix(string(contents), "\n"), "\n")
+		return nil
+	}
+}
+
 func date(year, month, day int, add time.Duration) *time.Time {
 	res := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC).Add(add)
 	return &res
@@ -45,17 +71,17 @@ func date(year, month, day int, add time.Duration) *time.Time {
 
 var eventTasks = map[string][]tasks.Task{
 	"zero.md": {
-		tasks.NewTask(tasks.NewIdentifier("zero.md", "version", 1), "Task zero-0", tasks.Abandoned),
-		tasks.NewTask(tasks.NewIdentifier("zero.md", "version", 2), "Task zero-1", tasks.Done, tasks.WithPriority(1)),
-		tasks.NewTask(tasks.NewIdentifier("zero.md", "version", 3), "Task zero-2", tasks.Doing, tasks.WithPriority(1), tasks.WithDue(*date(1, 1, 1, 0)), tasks.WithCompleted(*date(1, 1, 1, 0))),
-		tasks.NewTask(tasks.NewIdentifier("zero.md", "version", 4), "Task zero-3", tasks.Doing, tasks.WithDue(*date(1, 1, 2, 0)), tasks.WithCompleted(*date(1, 1, 2, 0))),
-		tasks.NewTask(tasks.NewIdentifier("zero.md", "version", 5), "Task zero-4", tasks.Doing, tasks.WithDue(*date(1, 1, 3, 0)), tasks.WithCompleted(*date(1, 1, 3, 0))),
+		tasks.NewTask(tasks.NewIdentifier("zero.md", "version", 1), "Task zero-0", tasks.Abandoned, tasks.WithSource("- [a] Task zero-0")),
+		tasks.NewTask(tasks.NewIdentifier("zero.md", "version", 2), "Task zero-1", tasks.Done, tasks.WithPriority(1), tasks.WithSource("- [x] Task zero-1 p:1")),
+		tasks.NewTask(tasks.NewIdentifier("zero.md", "version", 3), "Task zero-2", tasks.Doing, tasks.WithPriority(1), tasks.WithDue(*date(1, 1, 1, 0)), tasks.WithCompleted(*date(1, 1, 1, 0)), tasks.WithSource("- [/] Task zero-2 p:1 due:0001-01-01 completed:0001-01-01")),
+		tasks.NewTask(tasks.NewIdentifier("zero.md", "version", 4), "Task zero-3", tasks.Doing, tasks.WithDue(*date(1, 1, 2, 0)), tasks.WithCompleted(*date(1, 1, 2, 0)), tasks.WithSource("- [/] Task zero-3 due:0001-01-02 completed:0001-01-02")),
+		tasks.NewTask(tasks.NewIdentifier("zero.md", "version", 5), "Task zero-4", tasks.Doing, tasks.WithDue(*date(1, 1, 3, 0)), tasks.WithCompleted(*date(1, 1, 3, 0)), tasks.WithSource("- [/] Task zero-4 due:0001-01-03 completed:0001-01-03")),
 	},
 	"one.md": {
-		tasks.NewTask(tasks.NewIdentifier("one.md", "version", 1), "Task one-0", tasks.Doing, tasks.WithPriority(2)),
-		tasks.NewTask(tasks.NewIdentifier("one.md", "version", 2), "Task one-1", tasks.Todo, tasks.WithPriority(3)),
-		tasks.NewTask(tasks.NewIdentifier("one.md", "version", 3), "Task one-2", tasks.Blocked, tasks.WithPriority(4)),
-		tasks.NewTask(tasks.NewIdentifier("one.md", "version", 4), "Task one-3", tasks.Blocked, tasks.WithPriority(4)),
+		tasks.NewTask(tasks.NewIdentifier("one.md", "version", 1), "Task one-0", tasks.Doing, tasks.WithPriority(2), tasks.WithSource("- [/] Task one-0 p:2")),
+		tasks.NewTask(tasks.NewIdentifier("one.md", "version", 2), "Task one-1", tasks.Todo, tasks.WithPriority(3), tasks.WithSource("- [ ] Task one-1 p:3")),
+		tasks.NewTask(tasks.NewIdentifier("one.md", "version", 3), "Task one-2", tasks.Blocked, tasks.WithPriority(4), tasks.WithSource("- [b] Task one-2 p:4")),
+		tasks.NewTask(tasks.NewIdentifier("one.md", "version", 4), "Task one-3", tasks.Blocked, tasks.WithPriority(4), tasks.WithSource("- [b] Task one-3 p:4")),
 	},
 	"two.md":   {},
 	"three.md": {},
@@ -130,3 +156,60 @@ func loadEvents() []reader.Event {
 		},
 	}
 }
+
+// A single document with nested subtasks and notes
+func subtaskEvents() []reader.Event {
+	return []reader.Event{
+		{
+			Op:  reader.Load,
+			Key: "nested.md",
+			Document: reader.Document{
+				Contents: []byte(`- [ ] Parent every:day
+  - [x] Child one
+  - [ ] Child two
+    - [ ] Grandchild
+      Notes on the grandchild
+- [ ] Sibling
+`),
+				Checksum: "version",
+			},
+		},
+		{Op: reader.SubscriberLoadComplete},
+	}
+}
+
+// Due dates with and without times
+func mixedPrecisionEvents() []reader.Event {
+	return []reader.Event{
+		{
+			Op:  reader.Load,
+			Key: "times.md",
+			Document: reader.Document{
+				Contents: []byte(`- [ ] All day due:2024-01-01
+- [ ] Afternoon due:2024-01-01T14:00
+- [ ] Morning due:2024-01-01 09:00
+- [ ] Next day due:2024-01-02T08:00+01:00
+- [ ] Whenever
+`),
+				Checksum: "version",
+			},
+		},
+		{Op: reader.SubscriberLoadComplete},
+	}
+}
+
+// A daily note with relative dates, these resolve against the note's date
+func dailyEvents() []reader.Event {
+	return []reader.Event{
+		{
+			Op:  reader.Load,
+			Key: "daily/2024-11-06.md",
+			Document: reader.Document{
+				Metadata: reader.Metadata{reader.MetadataTypeKey: "daily"},
+				Contents: []byte("- [ ] Call the bank due:tomorrow\n- [ ] Plan the week s:next mon\n"),
+				Checksum: "version",
+			},
+		},
+		{Op: reader.SubscriberLoadComplete},
+	}
+}
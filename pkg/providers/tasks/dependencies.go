@@ -0,0 +1,126 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"log/slog"
+	"math/rand/v2"
+	"slices"
+	"strings"
+)
+
+// Replace the IDs of the tasks in the document with the new tasks, the caller must hold the write lock
+func (c *Client) index(path string, tasks map[int]Task) {
+	for _, task := range c.tasks[path] {
+		if task.id == "" {
+			continue
+		}
+		remaining := slices.DeleteFunc(c.ids[task.id], func(i Identifier) bool { return i.path == path })
+		if len(remaining) == 0 {
+			delete(c.ids, task.id)
+		} else {
+			c.ids[task.id] = remaining
+		}
+	}
+	for _, task := range tasks {
+		if task.id == "" {
+			continue
+		}
+		c.ids[task.id] = append(c.ids[task.id], task.Identifier())
+		if len(c.ids[task.id]) > 1 {
+			slog.Warn("duplicate task id", slog.String("id", task.id), slog.String("file", path), slog.Int("line", task.Line()))
+		}
+	}
+}
+
+// Find a task by its ID. If the ID is used more than once the first by path and line is returned.
+func (c *Client) TaskByID(id string) (Task, bool) {
+	c.tasksMutex.RLock()
+	defer c.tasksMutex.RUnlock()
+	task, ok := c.lookup(id)
+	if !ok {
+		return Task{}, false
+	}
+	return c.resolve(task), true
+}
+
+// The caller must hold the read lock
+func (c *Client) lookup(id string) (Task, bool) {
+	identifiers := c.ids[id]
+	if len(identifiers) == 0 {
+		return Task{}, false
+	}
+	first := slices.MinFunc(identifiers, func(a, b Identifier) int {
+		if a.path != b.path {
+			return strings.Compare(a.path, b.path)
+		}
+		return a.line - b.line
+	})
+	task, ok := c.tasks[first.path][first.line]
+	return task, ok
+}
+
+// Populate the task's dependency state from the rest of the workspace, the caller must hold the read lock
+func (c *Client) resolve(t Task) Task {
+	if len(t.after) == 0 {
+		return t
+	}
+	for _, id := range t.after {
+		dependency, ok := c.lookup(id)
+		if !ok || !dependency.Closed() {
+			t.blockedBy = append(t.blockedBy, id)
+		}
+	}
+	t.cycle = t.id != "" && c.dependsOn(t.after, t.id)
+	return t
+}
+
+// Whether any of the IDs (or their dependencies) depend on target
+func (c *Client) dependsOn(ids []string, target string) bool {
+	visited := make(map[string]bool)
+	queue := slices.Clone(ids)
+	for len(queue) > 0 {
+		id := queue[0]
+		queue = queue[1:]
+		if id == target {
+			return true
+		}
+		if visited[id] {
+			continue
+		}
+		visited[id] = true
+		if dependency, ok := c.lookup(id); ok {
+			queue = append(queue, dependency.after...)
+		}
+	}
+	return false
+}
+
+const idAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
+
+// Generate an ID that isn't used anywhere in the workspace
+func (c *Client) generateID() string {
+	c.tasksMutex.RLock()
+	defer c.tasksMutex.RUnlock()
+	for {
+		var b strings.Builder
+		for range 6 {
+			b.WriteByte(idAlphabet[rand.IntN(len(idAlphabet))])
+		}
+		if _, ok := c.ids[b.String()]; !ok {
+			return b.String()
+		}
+	}
+}
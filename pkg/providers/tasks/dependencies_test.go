@@ -0,0 +1,116 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/notedownorg/notedown/pkg/fileserver/reader"
+	"github.com/notedownorg/notedown/pkg/providers/tasks"
+	"github.com/stretchr/testify/assert"
+)
+
+func dependencyEvents() []reader.Event {
+	return []reader.Event{
+		{
+			Op:  reader.Load,
+			Key: "one.md",
+			Document: reader.Document{
+				Contents: []byte(`- [x] Design id:design
+- [ ] Build id:build after:design
+- [ ] Release after:build,docs
+`),
+				Checksum: "version",
+			},
+		},
+		{
+			Op:  reader.Load,
+			Key: "two.md",
+			Document: reader.Document{
+				Contents: []byte(`- [ ] Docs id:docs after:missing
+- [ ] Chicken id:chicken after:egg
+- [ ] Egg id:egg after:chicken
+`),
+				Checksum: "version",
+			},
+		},
+		{Op: reader.SubscriberLoadComplete},
+	}
+}
+
+func TestDependencies(t *testing.T) {
+	c, _ := buildClient(dependencyEvents())
+
+	byName := make(map[string]tasks.Task)
+	for _, task := range c.ListTasks(tasks.FetchAllTasks()) {
+		byName[task.Name()] = task
+	}
+
+	tests := []struct {
+		name      string
+		blockedBy []string
+		cycle     bool
+		status    tasks.Status
+	}{
+		{name: "Design", status: tasks.Done},
+		{name: "Build", status: tasks.Todo},
+		{name: "Release", blockedBy: []string{"build", "docs"}, status: tasks.Blocked},
+		{name: "Docs", blockedBy: []string{"missing"}, status: tasks.Blocked},
+		{name: "Chicken", blockedBy: []string{"egg"}, cycle: true, status: tasks.Blocked},
+		{name: "Egg", blockedBy: []string{"chicken"}, cycle: true, status: tasks.Blocked},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task := byName[test.name]
+			assert.Equal(t, test.blockedBy, task.BlockedBy())
+			assert.Equal(t, test.cycle, task.InCycle())
+			assert.Equal(t, test.status, task.EffectiveStatus())
+		})
+	}
+
+	ready := c.ListTasks(tasks.FetchAllTasks(), tasks.WithFilters(tasks.FilterByReady()))
+	assert.Len(t, ready, 1)
+	assert.Equal(t, "Build", ready[0].Name())
+
+	task, ok := c.TaskByID("docs")
+	assert.True(t, ok)
+	assert.Equal(t, tasks.NewIdentifier("two.md", "version", 1), task.Identifier())
+	assert.Equal(t, []string{"missing"}, task.BlockedBy())
+	_, ok = c.TaskByID("missing")
+	assert.False(t, ok)
+}
+
+func TestDependencies_Index(t *testing.T) {
+	c, feed := buildClient(dependencyEvents())
+
+	// Completing the dependency unblocks its dependents
+	feed <- reader.Event{Op: reader.Change, Key: "one.md", Document: reader.Document{Contents: []byte("- [x] Build id:build\n- [ ] Release after:build\n"), Checksum: "next"}}
+	assert.Eventually(t, func() bool {
+		task, ok := c.TaskByID("build")
+		return ok && task.Status() == tasks.Done
+	}, time.Second, 10*time.Millisecond)
+	_, ok := c.TaskByID("design")
+	assert.False(t, ok, "ids removed from a document should be removed from the index")
+	ready := c.ListTasks(tasks.FetchTasksForDocument("one.md"), tasks.WithFilters(tasks.FilterByReady()))
+	assert.Len(t, ready, 1)
+	assert.Equal(t, "Release", ready[0].Name())
+
+	feed <- reader.Event{Op: reader.Delete, Key: "two.md"}
+	assert.Eventually(t, func() bool {
+		_, ok := c.TaskByID("docs")
+		return !ok
+	}, time.Second, 10*time.Millisecond)
+}
@@ -16,11 +16,15 @@ package tasks
 
 import (
 	"log/slog"
+	"path/filepath"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/a-h/parse"
 	"github.com/notedownorg/notedown/pkg/fileserver/reader"
 	. "github.com/notedownorg/notedown/pkg/parsers"
+	"github.com/notedownorg/notedown/pkg/providers/pkg/doctypes"
 	"github.com/notedownorg/notedown/pkg/providers/pkg/traits"
 )
 
@@ -41,6 +45,7 @@ func onChange(c *Client) traits.EventHandler {
 func onDelete(c *Client) traits.EventHandler {
 	return func(event reader.Event) {
 		c.tasksMutex.Lock()
+		c.index(event.Key, nil)
 		delete(c.tasks, event.Key)
 		c.tasksMutex.Unlock()
 		c.publisher.Events <- Event{Op: Delete}
@@ -52,7 +57,7 @@ func (c *Client) handleChanges(event reader.Event) {
 
 	// Go through the contents block by block in search of tasks
 	in := parse.NewInput(string(event.Document.Contents))
-	blocks, ok, err := parse.Until(parseBlock(event.Key, event.Document.Checksum, time.Now()), parse.EOF[string]()).Parse(in)
+	blocks, ok, err := parse.Until(parseBlock(event.Key, event.Document.Checksum, referenceDate(event), c.statuses), parse.EOF[string]()).Parse(in)
 	if err != nil {
 		slog.Error("failed to parse blocks", slog.String("file", event.Key), slog.String("error", err.Error()))
 		return
@@ -62,17 +67,34 @@ func (c *Client) handleChanges(event reader.Event) {
 		return
 	}
 	for _, block := range blocks {
-		for _, task := range block {
+		for _, task := range linkSubtasks(block) {
+			if task.every != nil && task.every.err != nil {
+				slog.Warn("invalid recurrence", slog.String("file", event.Key), slog.Int("line", task.Line()), slog.String("error", task.every.err.Error()))
+			}
 			tasks[task.Line()] = task
 		}
 	}
 
 	c.tasksMutex.Lock()
+	c.index(event.Key, tasks)
 	c.tasks[event.Key] = tasks
 	c.tasksMutex.Unlock()
 }
 
-var parseBlock = func(path, version string, relativeTo time.Time) parse.Parser[[]Task] {
+// Relative dates in daily notes are relative to the note's date, everywhere else they are relative to now
+func referenceDate(event reader.Event) time.Time {
+	if event.Document.Metadata.Type() != doctypes.Daily {
+		return time.Now()
+	}
+	name := strings.TrimSuffix(filepath.Base(event.Key), filepath.Ext(event.Key))
+	date, err := time.Parse(DateLayout, name)
+	if err != nil {
+		return time.Now()
+	}
+	return date
+}
+
+var parseBlock = func(path, version string, relativeTo time.Time, statuses *Statuses) parse.Parser[[]Task] {
 	return parse.Func(func(in *parse.Input) ([]Task, bool, error) {
 		var res []Task
 
@@ -80,7 +102,7 @@ var parseBlock = func(path, version string, relativeTo time.Time) parse.Parser[[
 		_, _, err := parse.NewLine.Parse(in)
 
 		for {
-			task, ok, err := ParseTask(path, version, relativeTo).Parse(in)
+			task, ok, err := parseTask(path, version, relativeTo, statuses).Parse(in)
 			if err != nil {
 				return nil, false, err
 			}
@@ -100,3 +122,67 @@ var parseBlock = func(path, version string, relativeTo time.Time) parse.Parser[[
 		return res, true, nil
 	})
 }
+
+// Link each task in a block to its parent, a task is a subtask of the closest preceding task that is indented less than it
+func linkSubtasks(block []Task) []Task {
+	parents := make([]int, len(block))
+	var stack []int
+	for i := range block {
+		width := indentWidth(block[i].indent)
+		for len(stack) > 0 && indentWidth(block[stack[len(stack)-1]].indent) >= width {
+			stack = stack[:len(stack)-1]
+		}
+		parents[i] = -1
+		if len(stack) > 0 {
+			parent := stack[len(stack)-1]
+			parents[i] = parent
+			block[i].parent = block[parent].Line()
+			block[i].depth = len(stack)
+			block[parent].children = append(block[parent].children, block[i].Line())
+		}
+		stack = append(stack, i)
+	}
+
+	// Work backwards so each task's subtree is complete before it is added to its parent
+	for i := len(block) - 1; i >= 0; i-- {
+		parent := parents[i]
+		if parent == -1 {
+			continue
+		}
+		block[parent].end = max(block[parent].end, block[i].last())
+		block[parent].subtasks += block[i].subtasks + 1
+		block[parent].subtasksDone += block[i].subtasksDone
+		if block[i].Closed() {
+			block[parent].subtasksDone++
+		}
+	}
+
+	// Keep each task's subtasks as they were parsed so writes to the task don't depend on the cache, which may have
+	// been updated with a newer version of the document by the time the task is written
+	for i := len(block) - 1; i >= 0; i-- {
+		end := i + 1
+		for end < len(block) && block[end].Line() <= block[i].last() {
+			end++
+		}
+		if end > i+1 {
+			block[i].descendants = slices.Clone(block[i+1 : end])
+		}
+	}
+	return block
+}
+
+// The width of the line's leading whitespace, tabs are treated as 4 spaces so mixed indentation still nests sensibly
+func indentWidth(line string) int {
+	width := 0
+	for _, r := range line {
+		switch r {
+		case ' ':
+			width++
+		case '\t':
+			width += 4
+		default:
+			return width
+		}
+	}
+	return width
+}
@@ -16,6 +16,7 @@ package tasks_test
 
 import (
 	"math/rand"
+	"sort"
 	"testing"
 	"time"
 
@@ -73,3 +74,55 @@ func TestEventBroadcast_Fuzz(t *testing.T) {
 	assert.ElementsMatch(t, want, got1)
 	assert.ElementsMatch(t, want, got2)
 }
+
+func TestHandleChanges_Subtasks(t *testing.T) {
+	c, _ := buildClient(subtaskEvents())
+	got := c.ListTasks(tasks.FetchAllTasks())
+	sort.Slice(got, func(i, j int) bool { return got[i].Line() < got[j].Line() })
+	assert.Len(t, got, 5)
+	parent, childOne, childTwo, grandchild, sibling := got[0], got[1], got[2], got[3], got[4]
+
+	id := func(line int) tasks.Identifier { return tasks.NewIdentifier("nested.md", "version", line) }
+
+	assert.Nil(t, parent.Parent())
+	assert.True(t, parent.IsTopLevel())
+	assert.False(t, parent.IsLeaf())
+	assert.Equal(t, 0, parent.Depth())
+	assert.Equal(t, []tasks.Identifier{id(2), id(3)}, parent.Children())
+	done, total := parent.Progress()
+	assert.Equal(t, 1, done)
+	assert.Equal(t, 3, total)
+
+	assert.Equal(t, id(1), *childOne.Parent())
+	assert.True(t, childOne.IsLeaf())
+	assert.Equal(t, 1, childOne.Depth())
+	assert.Equal(t, "  ", childOne.Indent())
+
+	assert.Equal(t, id(1), *childTwo.Parent())
+	assert.Equal(t, []tasks.Identifier{id(4)}, childTwo.Children())
+	done, total = childTwo.Progress()
+	assert.Equal(t, 0, done)
+	assert.Equal(t, 1, total)
+
+	assert.Equal(t, id(3), *grandchild.Parent())
+	assert.Equal(t, 2, grandchild.Depth())
+	assert.Equal(t, "    - [ ] Grandchild", grandchild.String())
+	assert.Equal(t, []string{"      Notes on the grandchild"}, grandchild.Notes())
+	start, end := grandchild.NotesRange()
+	assert.Equal(t, 5, start)
+	assert.Equal(t, 5, end)
+
+	assert.True(t, sibling.IsTopLevel())
+	assert.True(t, sibling.IsLeaf())
+	done, total = sibling.Progress()
+	assert.Equal(t, 0, done)
+	assert.Equal(t, 0, total)
+}
+
+func TestHandleChanges_DailyNoteRelativeDates(t *testing.T) {
+	c, _ := buildClient(dailyEvents())
+	got := c.ListTasks(tasks.FetchAllTasks(), tasks.WithSorters(tasks.SortByDueDate()))
+	assert.Len(t, got, 2)
+	assert.Equal(t, *date(2024, 11, 7, 0), *got[0].Due())
+	assert.Equal(t, *date(2024, 11, 11, 0), *got[1].Scheduled())
+}
@@ -22,7 +22,7 @@ func FetchAllTasks() collections.Fetcher[Client, Task] {
 		c.tasksMutex.RLock()
 		for _, document := range c.tasks {
 			for _, task := range document {
-				tasks = append(tasks, task)
+				tasks = append(tasks, c.resolve(task))
 			}
 		}
 		c.tasksMutex.RUnlock()
@@ -35,7 +35,7 @@ func FetchTasksForDocument(document string) collections.Fetcher[Client, Task] {
 		var tasks []Task
 		c.tasksMutex.RLock()
 		for _, task := range c.tasks[document] {
-			tasks = append(tasks, task)
+			tasks = append(tasks, c.resolve(task))
 		}
 		c.tasksMutex.RUnlock()
 		return tasks
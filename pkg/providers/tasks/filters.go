@@ -15,8 +15,11 @@
 package tasks
 
 import (
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/notedownorg/notedown/pkg/parsers"
 	"github.com/notedownorg/notedown/pkg/providers/pkg/collections"
 )
 
@@ -52,32 +55,97 @@ func FilterByStatus(status ...Status) collections.Filter[Task] {
 }
 
 // Following Go's time package, after and before are inclusive (include equal to).
+// Dates without a time cover the whole day so match if any part of the day is in range.
 func FilterByDueDate(after *time.Time, before *time.Time) collections.Filter[Task] {
 	return func(t Task) bool {
-		if t.Due() == nil {
-			return false
-		}
-		if after != nil && t.Due().Before(*after) {
-			return false
-		}
-		if before != nil && t.Due().After(*before) {
-			return false
-		}
-		return true
+		return inRange(t.due, after, before)
 	}
 }
 
 func FilterByCompletedDate(after *time.Time, before *time.Time) collections.Filter[Task] {
 	return func(t Task) bool {
-		if t.Completed() == nil {
-			return false
-		}
-		if after != nil && t.Completed().Before(*after) {
-			return false
-		}
-		if before != nil && t.Completed().After(*before) {
+		return inRange(t.completed, after, before)
+	}
+}
+
+func inRange(ts *parsers.Timestamp, after *time.Time, before *time.Time) bool {
+	if ts == nil {
+		return false
+	}
+	if after != nil && ts.End().Before(*after) {
+		return false
+	}
+	if before != nil && ts.Time.After(*before) {
+		return false
+	}
+	return true
+}
+
+// Leaf tasks are tasks without any subtasks
+func FilterByLeaf() collections.Filter[Task] {
+	return func(t Task) bool {
+		return t.IsLeaf()
+	}
+}
+
+// Top level tasks are tasks that aren't a subtask of another task
+func FilterByTopLevel() collections.Filter[Task] {
+	return func(t Task) bool {
+		return t.IsTopLevel()
+	}
+}
+
+// Tags are AND'd together because a task can have multiple tags, use collections.Or to match any of them.
+// Tags are matched case insensitively and can be given with or without the leading #.
+func FilterByTag(tags ...string) collections.Filter[Task] {
+	return func(t Task) bool {
+		return hasMarkers(t.Tags(), tagPrefix, tags)
+	}
+}
+
+// Contexts are AND'd together in the same way as tags and can be given with or without the leading @.
+func FilterByContext(contexts ...string) collections.Filter[Task] {
+	return func(t Task) bool {
+		return hasMarkers(t.Contexts(), contextPrefix, contexts)
+	}
+}
+
+// Projects are AND'd together in the same way as tags and can be given with or without the leading +.
+func FilterByProject(projects ...string) collections.Filter[Task] {
+	return func(t Task) bool {
+		return hasMarkers(t.Projects(), projectPrefix, projects)
+	}
+}
+
+func hasMarkers(markers []string, prefix rune, want []string) bool {
+	for _, w := range want {
+		w = strings.TrimPrefix(w, string(prefix))
+		if !slices.ContainsFunc(markers, func(m string) bool { return strings.EqualFold(m, w) }) {
 			return false
 		}
-		return true
+	}
+	return true
+}
+
+// Available tasks aren't deferred, i.e. they have no start date or it isn't after the given time.
+// A start date without a time makes the task available from the beginning of that day.
+func FilterByAvailable(at time.Time) collections.Filter[Task] {
+	return func(t Task) bool {
+		return t.start == nil || !t.start.Time.After(at)
+	}
+}
+
+// Categories are OR'd together because a task's status only has one category.
+func FilterByCategory(categories ...Category) collections.Filter[Task] {
+	return func(t Task) bool {
+		return slices.Contains(categories, t.Category())
+	}
+}
+
+// Ready tasks aren't closed, blocked or waiting (on other tasks or otherwise)
+func FilterByReady() collections.Filter[Task] {
+	return func(t Task) bool {
+		status := t.EffectiveStatus()
+		return !t.Closed() && status != Blocked && status != Waiting
 	}
 }
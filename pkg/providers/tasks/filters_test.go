@@ -16,7 +16,9 @@ package tasks_test
 
 import (
 	"testing"
+	"time"
 
+	"github.com/notedownorg/notedown/pkg/fileserver/reader"
 	"github.com/notedownorg/notedown/pkg/providers/pkg/collections"
 	"github.com/notedownorg/notedown/pkg/providers/tasks"
 	"github.com/stretchr/testify/assert"
@@ -125,3 +127,103 @@ func TestTaskFilters(t *testing.T) {
 		})
 	}
 }
+
+func TestTaskFilters_Subtasks(t *testing.T) {
+	c, _ := buildClient(subtaskEvents())
+
+	names := func(filter collections.Filter[tasks.Task]) []string {
+		var res []string
+		for _, task := range c.ListTasks(tasks.FetchAllTasks(), tasks.WithFilters(filter)) {
+			res = append(res, task.Name())
+		}
+		return res
+	}
+
+	assert.ElementsMatch(t, []string{"Child one", "Grandchild", "Sibling"}, names(tasks.FilterByLeaf()))
+	assert.ElementsMatch(t, []string{"Parent", "Sibling"}, names(tasks.FilterByTopLevel()))
+}
+
+func TestTaskFilters_Markers(t *testing.T) {
+	c, _ := buildClient([]reader.Event{
+		{
+			Op:  reader.Load,
+			Key: "markers.md",
+			Document: reader.Document{
+				Contents: []byte(`- [ ] Call the bank @phone +house
+- [ ] Buy paint @errands +house #diy
+- [ ] Call mum @phone #family
+`),
+				Checksum: "version",
+			},
+		},
+		{Op: reader.SubscriberLoadComplete},
+	})
+
+	names := func(filter collections.Filter[tasks.Task]) []string {
+		var res []string
+		for _, task := range c.ListTasks(tasks.FetchAllTasks(), tasks.WithFilters(filter)) {
+			res = append(res, task.Name())
+		}
+		return res
+	}
+
+	assert.ElementsMatch(t, []string{"Call the bank @phone +house", "Call mum @phone #family"}, names(tasks.FilterByContext("phone")))
+	assert.ElementsMatch(t, []string{"Call the bank @phone +house", "Call mum @phone #family"}, names(tasks.FilterByContext("@PHONE")))
+	assert.ElementsMatch(t, []string{"Call the bank @phone +house"}, names(collections.And(tasks.FilterByContext("phone"), tasks.FilterByProject("+house"))))
+	assert.ElementsMatch(t, []string{"Buy paint @errands +house #diy", "Call mum @phone #family"}, names(collections.Or(tasks.FilterByTag("diy"), tasks.FilterByTag("family"))))
+	assert.Empty(t, names(tasks.FilterByTag("diy", "family")))
+}
+
+func TestTaskFilters_MixedPrecision(t *testing.T) {
+	c, _ := buildClient(mixedPrecisionEvents())
+
+	names := func(filter collections.Filter[tasks.Task]) []string {
+		var res []string
+		for _, task := range c.ListTasks(tasks.FetchAllTasks(), tasks.WithFilters(filter)) {
+			res = append(res, task.Name())
+		}
+		return res
+	}
+
+	// The all day task overlaps with the afternoon
+	assert.ElementsMatch(t, []string{"All day", "Afternoon"}, names(tasks.FilterByDueDate(date(2024, 1, 1, 12*time.Hour), date(2024, 1, 1, 18*time.Hour))))
+	assert.ElementsMatch(t, []string{"All day", "Afternoon", "Morning"}, names(tasks.FilterByDueDate(nil, date(2024, 1, 1, 23*time.Hour))))
+
+	// The zone offset is taken into account, 08:00+01:00 is 07:00 UTC
+	assert.ElementsMatch(t, []string{"Next day"}, names(tasks.FilterByDueDate(date(2024, 1, 2, 7*time.Hour), nil)))
+	assert.Empty(t, names(tasks.FilterByDueDate(date(2024, 1, 2, 7*time.Hour+time.Minute), nil)))
+}
+
+func TestTaskFilters_Available(t *testing.T) {
+	c, _ := buildClient([]reader.Event{
+		{
+			Op:  reader.Load,
+			Key: "deferred.md",
+			Document: reader.Document{
+				Contents: []byte(`- [ ] Now
+- [ ] Tomorrow start:2024-01-02
+- [ ] Tomorrow afternoon defer:2024-01-02T14:00
+- [w] Waiting on Sam start:2024-01-01
+`),
+				Checksum: "version",
+			},
+		},
+		{Op: reader.SubscriberLoadComplete},
+	})
+
+	names := func(filter collections.Filter[tasks.Task]) []string {
+		var res []string
+		for _, task := range c.ListTasks(tasks.FetchAllTasks(), tasks.WithFilters(filter)) {
+			res = append(res, task.Name())
+		}
+		return res
+	}
+
+	assert.ElementsMatch(t, []string{"Now", "Waiting on Sam"}, names(tasks.FilterByAvailable(*date(2024, 1, 1, 23*time.Hour))))
+	assert.ElementsMatch(t, []string{"Now", "Tomorrow", "Waiting on Sam"}, names(tasks.FilterByAvailable(*date(2024, 1, 2, 0))))
+	assert.ElementsMatch(t, []string{"Now", "Tomorrow", "Tomorrow afternoon", "Waiting on Sam"}, names(tasks.FilterByAvailable(*date(2024, 1, 2, 14*time.Hour))))
+
+	// Waiting tasks are available but not ready
+	assert.ElementsMatch(t, []string{"Now"}, names(collections.And(tasks.FilterByAvailable(*date(2024, 1, 1, 0)), tasks.FilterByReady())))
+	assert.ElementsMatch(t, []string{"Waiting on Sam"}, names(tasks.FilterByStatus(tasks.Waiting)))
+}
@@ -0,0 +1,179 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/a-h/parse"
+	"github.com/notedownorg/notedown/pkg/parsers"
+)
+
+type field int
+
+// In the order fields are written for tasks that weren't parsed from a document
+const (
+	fieldDue field = iota
+	fieldScheduled
+	fieldStart
+	fieldPriority
+	fieldEvery
+	fieldID
+	fieldAfter
+	fieldEstimate
+	fieldSpent
+	fieldStarted
+	fieldCompleted
+)
+
+type fieldFormat struct {
+	// The key and value the task would be written with, not ok if the task doesn't have the field
+	format func(t Task) (key string, value string, ok bool)
+	// Dates can be written relative to today so are only kept as written if they're already absolute
+	date bool
+}
+
+var fieldFormats = []fieldFormat{
+	fieldDue:       {format: timestamp("due:", func(t Task) *parsers.Timestamp { return t.due }), date: true},
+	fieldScheduled: {format: timestamp("scheduled:", func(t Task) *parsers.Timestamp { return t.scheduled }), date: true},
+	fieldStart:     {format: timestamp("start:", func(t Task) *parsers.Timestamp { return t.start }), date: true},
+	fieldPriority: {format: func(t Task) (string, string, bool) {
+		if t.priority == nil {
+			return "", "", false
+		}
+		return "priority:", fmt.Sprint(*t.priority), true
+	}},
+	fieldEvery: {format: func(t Task) (string, string, bool) {
+		if t.every == nil {
+			return "", "", false
+		}
+		if t.every.bang {
+			return "every!:", t.every.text, true
+		}
+		return "every:", t.every.text, true
+	}},
+	fieldID: {format: func(t Task) (string, string, bool) { return "id:", t.id, t.id != "" }},
+	fieldAfter: {format: func(t Task) (string, string, bool) {
+		return "after:", strings.Join(t.after, ","), len(t.after) > 0
+	}},
+	fieldEstimate:  {format: duration("estimate:", func(t Task) *time.Duration { return t.estimate })},
+	fieldSpent:     {format: duration("spent:", func(t Task) *time.Duration { return t.spent })},
+	fieldStarted:   {format: timestamp("started:", func(t Task) *parsers.Timestamp { return t.started }), date: true},
+	fieldCompleted: {format: timestamp("completed:", func(t Task) *parsers.Timestamp { return t.completed }), date: true},
+}
+
+func timestamp(key string, get func(Task) *parsers.Timestamp) func(Task) (string, string, bool) {
+	return func(t Task) (string, string, bool) {
+		if ts := get(t); ts != nil {
+			return key, ts.String(), true
+		}
+		return "", "", false
+	}
+}
+
+func duration(key string, get func(Task) *time.Duration) func(Task) (string, string, bool) {
+	return func(t Task) (string, string, bool) {
+		if d := get(t); d != nil {
+			return key, parsers.FormatDuration(*d), true
+		}
+		return "", "", false
+	}
+}
+
+// Where the name and each field were found in the line a task was parsed from, as offsets from the start of the line
+type layout struct {
+	nameStart, nameEnd int
+	fields             []fieldSpan
+}
+
+type fieldSpan struct {
+	field field
+	// Includes the whitespace before the key so removing the field doesn't leave a double space behind
+	start, end int
+}
+
+func (l *layout) name(start, end int) {
+	if l != nil {
+		l.nameStart, l.nameEnd = start, end
+	}
+}
+
+func (l *layout) field(f field, start, end int) {
+	if l != nil {
+		l.fields = append(l.fields, fieldSpan{field: f, start: start, end: end})
+	}
+}
+
+// Write the task back over the line it was parsed from. Only the name and fields that have changed are rewritten
+// (keeping the key they were written with), fields that have been removed are dropped and new fields are added to the
+// end. Everything else, e.g. text between or after the fields, is kept as it was.
+func (t Task) preservedBody() (string, bool) {
+	l := &layout{}
+	original, ok, err := parseTaskLayout(t.Path(), t.Version(), time.Now(), t.registry(), l).Parse(parse.NewInput(t.source))
+	if err != nil || !ok {
+		return "", false
+	}
+
+	slices.SortFunc(l.fields, func(a, b fieldSpan) int { return cmp.Compare(a.start, b.start) })
+
+	var b strings.Builder
+	name := t.source[l.nameStart:l.nameEnd]
+	if trimmed := strings.TrimSpace(name); trimmed != t.name {
+		name = strings.Replace(name, trimmed, t.name, 1)
+	}
+	b.WriteString(name)
+
+	written := make(map[field]bool)
+	last := l.nameEnd
+	for _, span := range l.fields {
+		b.WriteString(t.source[last:span.start])
+		last = span.end
+		written[span.field] = true
+
+		format := fieldFormats[span.field]
+		key, value, ok := format.format(t)
+		if !ok {
+			continue
+		}
+		text := t.source[span.start:span.end]
+		colon := strings.Index(text, ":")
+		writtenKey, writtenValue := strings.TrimSpace(text[:colon+1]), strings.TrimSpace(text[colon+1:])
+		_, originalValue, _ := format.format(original)
+		if value == originalValue && (!format.date || writtenValue == originalValue) {
+			b.WriteString(text)
+			continue
+		}
+		// A changed every!: to every: (or the reverse) changes the key as well as the value
+		if strings.Contains(writtenKey, "!") == strings.Contains(key, "!") {
+			key = writtenKey
+		}
+		b.WriteString(text[:strings.Index(text, writtenKey)] + key + value)
+	}
+	b.WriteString(t.source[last:])
+
+	for f, format := range fieldFormats {
+		if written[field(f)] {
+			continue
+		}
+		if key, value, ok := format.format(t); ok {
+			b.WriteString(" " + key + value)
+		}
+	}
+	return b.String(), true
+}
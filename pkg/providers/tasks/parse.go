@@ -22,59 +22,70 @@ import (
 	. "github.com/notedownorg/notedown/pkg/parsers"
 )
 
-var statusLookup = map[string]Status{
-	" ": Todo,
-	"x": Done,
-	"X": Done,
-	"/": Doing,
-	"b": Blocked,
-	"B": Blocked,
-	"a": Abandoned,
-	"A": Abandoned,
-}
-
-var StatusRuneLookup = map[Status]rune{
-	Todo:      ' ',
-	Blocked:   'b',
-	Doing:     '/',
-	Done:      'x',
-	Abandoned: 'a',
-}
-
-var statusParser = parse.Func(func(in *parse.Input) (Status, bool, error) {
-	// Read the open bracket
-	_, ok, err := parse.Rune('[').Parse(in)
-	if err != nil || !ok {
-		return "", false, err
+// Deprecated: use DefaultStatuses (or the client's statuses) instead, this only includes the built in statuses.
+var StatusRuneLookup = func() map[Status]rune {
+	res := make(map[Status]rune)
+	for _, definition := range DefaultStatuses().All() {
+		res[definition.Status] = []rune(definition.Status)[0]
 	}
+	return res
+}()
 
-	// Read the status rune
-	s, ok, err := parse.RuneIn(" xX/bBaA").Parse(in)
-	if err != nil || !ok {
-		return "", false, err
-	}
+// Read a status between brackets e.g. [x], runes that aren't in the registry mean the line isn't a task
+var statusParser = func(statuses *Statuses) parse.Parser[Status] {
+	return parse.Func(func(in *parse.Input) (Status, bool, error) {
+		// Read the open bracket
+		_, ok, err := parse.Rune('[').Parse(in)
+		if err != nil || !ok {
+			return "", false, err
+		}
 
-	// Read the close bracket
-	_, ok, err = parse.Rune(']').Parse(in)
-	if err != nil || !ok {
-		return "", false, err
-	}
+		// Read the status rune
+		s, ok, err := parse.RuneWhere(func(r rune) bool { _, ok := statuses.Lookup(r); return ok }).Parse(in)
+		if err != nil || !ok {
+			return "", false, err
+		}
 
-	// Eat the trailing space
-	_, ok, err = parse.Rune(' ').Parse(in)
-	if err != nil || !ok {
-		return "", false, err
-	}
+		// Read the close bracket
+		_, ok, err = parse.Rune(']').Parse(in)
+		if err != nil || !ok {
+			return "", false, err
+		}
+
+		// Eat the trailing space
+		_, ok, err = parse.Rune(' ').Parse(in)
+		if err != nil || !ok {
+			return "", false, err
+		}
 
-	return statusLookup[s], true, nil
-})
+		definition, _ := statuses.Lookup([]rune(s)[0])
+		return definition.Status, true, nil
+	})
+}
 
 var listItemOpen = parse.StringFrom(RemainingInlineWhitespace, parse.Rune('-'), RemainingInlineWhitespace)
 
+// Parse a task with the default statuses
 var ParseTask = func(path string, checksum string, relativeTo time.Time) parse.Parser[Task] {
+	return parseTask(path, checksum, relativeTo, defaultStatuses)
+}
+
+var parseTask = func(path string, checksum string, relativeTo time.Time, statuses *Statuses) parse.Parser[Task] {
+	return parseTaskLayout(path, checksum, relativeTo, statuses, nil)
+}
+
+// Parse a task, recording where its name and fields were found in the line if a layout is given
+var parseTaskLayout = func(path string, checksum string, relativeTo time.Time, statuses *Statuses, l *layout) parse.Parser[Task] {
 	return parse.Func(func(in *parse.Input) (Task, bool, error) {
 		// Line is 1-indexed not 0-indexed, this is so it's a bit more user friendly and also to allow for 0 to represent the beginning of the file.
 		line, taskOpts := in.Position().Line+1, []TaskOption{}
+		begin := in.Index()
+
+		// Keep hold of the indentation so we can determine which tasks are subtasks
+		indent, _, err := RemainingInlineWhitespace.Parse(in)
+		if err != nil {
+			return Task{}, false, err
+		}
 
 		// Read and dump the list item open
 		_, ok, err := listItemOpen.Parse(in)
@@ -83,16 +94,18 @@ var ParseTask = func(path string, checksum string, relativeTo time.Time) parse.P
 		}
 
 		// Read the task status
-		status, ok, err := statusParser.Parse(in)
+		status, ok, err := statusParser(statuses).Parse(in)
 		if err != nil || !ok {
 			return Task{}, false, err
 		}
 
 		// Read until we hit a key, newline or eof to get the name.
+		nameStart := in.Index()
 		name, ok, err := parse.StringUntil(parse.Any(LeadingWhitespace(anyFieldKey), NewLineOrEOF)).Parse(in)
 		if err != nil || !ok {
 			return Task{}, false, err
 		}
+		l.name(nameStart-begin, in.Index()-begin)
 		name = strings.TrimSpace(name)
 
 		// Parse the fields
@@ -104,12 +117,14 @@ var ParseTask = func(path string, checksum string, relativeTo time.Time) parse.P
 			return Task{}, false, err
 		}
 		if ok {
-			due, ok, err := LeadingWhitespace(dueParser).Parse(in)
+			from := in.Index()
+			due, ok, err := LeadingWhitespace(dueParser(relativeTo)).Parse(in)
 			if err != nil {
 				return Task{}, false, err
 			}
 			if ok {
-				taskOpts = append(taskOpts, WithDue(due))
+				taskOpts = append(taskOpts, withDue(due))
+				l.field(fieldDue, from-begin, in.Index()-begin)
 			}
 		}
 		in.Seek(start)
@@ -120,12 +135,32 @@ var ParseTask = func(path string, checksum string, relativeTo time.Time) parse.P
 			return Task{}, false, err
 		}
 		if ok {
-			scheduled, ok, err := LeadingWhitespace(scheduledParser).Parse(in)
+			from := in.Index()
+			scheduled, ok, err := LeadingWhitespace(scheduledParser(relativeTo)).Parse(in)
+			if err != nil {
+				return Task{}, false, err
+			}
+			if ok {
+				taskOpts = append(taskOpts, withScheduled(scheduled))
+				l.field(fieldScheduled, from-begin, in.Index()-begin)
+			}
+		}
+		in.Seek(start)
+
+		// Start
+		_, ok, err = parse.StringUntil(parse.Any(LeadingWhitespace(startKey), NewLineOrEOF)).Parse(in)
+		if err != nil {
+			return Task{}, false, err
+		}
+		if ok {
+			from := in.Index()
+			deferred, ok, err := LeadingWhitespace(startParser(relativeTo)).Parse(in)
 			if err != nil {
 				return Task{}, false, err
 			}
 			if ok {
-				taskOpts = append(taskOpts, WithScheduled(scheduled))
+				taskOpts = append(taskOpts, withStart(deferred))
+				l.field(fieldStart, from-begin, in.Index()-begin)
 			}
 		}
 		in.Seek(start)
@@ -136,12 +171,14 @@ var ParseTask = func(path string, checksum string, relativeTo time.Time) parse.P
 			return Task{}, false, err
 		}
 		if ok {
+			from := in.Index()
 			completed, ok, err := LeadingWhitespace(completedParser).Parse(in)
 			if err != nil {
 				return Task{}, false, err
 			}
 			if ok {
-				taskOpts = append(taskOpts, WithCompleted(completed))
+				taskOpts = append(taskOpts, withCompleted(completed))
+				l.field(fieldCompleted, from-begin, in.Index()-begin)
 			}
 		}
 		in.Seek(start)
@@ -152,12 +189,68 @@ var ParseTask = func(path string, checksum string, relativeTo time.Time) parse.P
 			return Task{}, false, err
 		}
 		if ok {
+			from := in.Index()
 			priority, ok, err := LeadingWhitespace(priorityParser).Parse(in)
 			if err != nil {
 				return Task{}, false, err
 			}
 			if ok {
 				taskOpts = append(taskOpts, WithPriority(priority))
+				l.field(fieldPriority, from-begin, in.Index()-begin)
+			}
+		}
+		in.Seek(start)
+
+		// Estimate
+		_, ok, err = parse.StringUntil(parse.Any(LeadingWhitespace(estimateKey), NewLineOrEOF)).Parse(in)
+		if err != nil {
+			return Task{}, false, err
+		}
+		if ok {
+			from := in.Index()
+			estimate, ok, err := LeadingWhitespace(durationParser(estimateKey)).Parse(in)
+			if err != nil {
+				return Task{}, false, err
+			}
+			if ok {
+				taskOpts = append(taskOpts, WithEstimate(estimate))
+				l.field(fieldEstimate, from-begin, in.Index()-begin)
+			}
+		}
+		in.Seek(start)
+
+		// Spent
+		_, ok, err = parse.StringUntil(parse.Any(LeadingWhitespace(spentKey), NewLineOrEOF)).Parse(in)
+		if err != nil {
+			return Task{}, false, err
+		}
+		if ok {
+			from := in.Index()
+			spent, ok, err := LeadingWhitespace(durationParser(spentKey)).Parse(in)
+			if err != nil {
+				return Task{}, false, err
+			}
+			if ok {
+				taskOpts = append(taskOpts, WithSpent(spent))
+				l.field(fieldSpent, from-begin, in.Index()-begin)
+			}
+		}
+		in.Seek(start)
+
+		// Started
+		_, ok, err = parse.StringUntil(parse.Any(LeadingWhitespace(startedKey), NewLineOrEOF)).Parse(in)
+		if err != nil {
+			return Task{}, false, err
+		}
+		if ok {
+			from := in.Index()
+			started, ok, err := LeadingWhitespace(startedParser).Parse(in)
+			if err != nil {
+				return Task{}, false, err
+			}
+			if ok {
+				taskOpts = append(taskOpts, withStarted(started))
+				l.field(fieldStarted, from-begin, in.Index()-begin)
 			}
 		}
 		in.Seek(start)
@@ -168,20 +261,115 @@ var ParseTask = func(path string, checksum string, relativeTo time.Time) parse.P
 			return Task{}, false, err
 		}
 		if ok {
+			from := in.Index()
 			every, ok, err := LeadingWhitespace(everyParser(relativeTo)).Parse(in)
 			if err != nil {
 				return Task{}, false, err
 			}
 			if ok {
 				taskOpts = append(taskOpts, WithEvery(every))
+				l.field(fieldEvery, from-begin, in.Index()-begin)
+			}
+		}
+		in.Seek(start)
+
+		// ID
+		_, ok, err = parse.StringUntil(parse.Any(LeadingWhitespace(idKey), NewLineOrEOF)).Parse(in)
+		if err != nil {
+			return Task{}, false, err
+		}
+		if ok {
+			from := in.Index()
+			id, ok, err := LeadingWhitespace(idParser).Parse(in)
+			if err != nil {
+				return Task{}, false, err
+			}
+			if ok {
+				taskOpts = append(taskOpts, WithID(id))
+				l.field(fieldID, from-begin, in.Index()-begin)
 			}
 		}
 		in.Seek(start)
 
-		// Consume to the next line or eof.
-		parse.StringUntil(NewLineOrEOF).Parse(in)
+		// After
+		_, ok, err = parse.StringUntil(parse.Any(LeadingWhitespace(afterKey), NewLineOrEOF)).Parse(in)
+		if err != nil {
+			return Task{}, false, err
+		}
+		if ok {
+			from := in.Index()
+			after, ok, err := LeadingWhitespace(afterParser).Parse(in)
+			if err != nil {
+				return Task{}, false, err
+			}
+			if ok {
+				taskOpts = append(taskOpts, WithAfter(after...))
+				l.field(fieldAfter, from-begin, in.Index()-begin)
+			}
+		}
+		in.Seek(start)
+
+		// Consume to the next line or eof, keeping hold of the original line.
+		fields, _, err := parse.StringUntil(NewLineOrEOF).Parse(in)
+		if err != nil {
+			return Task{}, false, err
+		}
+		if markers := trailingMarkers(fields); len(markers) > 0 {
+			taskOpts = append(taskOpts, withTrailing(markers))
+		}
+		end := in.Index()
+		in.Seek(begin)
+		source, _ := in.Take(end - begin)
 		NewLineOrEOF.Parse(in)
 
+		// Anything indented under the task that isn't a task itself belongs to it
+		notes, _, err := parseNotes(indent, statuses).Parse(in)
+		if err != nil {
+			return Task{}, false, err
+		}
+
+		taskOpts = append(taskOpts, WithIndent(indent), WithNotes(notes...), withSource(source), withStatuses(statuses))
 		return NewTask(NewIdentifier(path, checksum, line), name, status, taskOpts...), true, nil
 	})
 }
+
+// Parse the lines indented further than the task that aren't tasks. Blank lines are only included when they are
+// followed by more notes so the blank lines separating the task from whatever comes next are left alone.
+var parseNotes = func(indent string, statuses *Statuses) parse.Parser[[]string] {
+	return parse.Func(func(in *parse.Input) ([]string, bool, error) {
+		var notes, blanks []string
+		end := in.Index()
+		for {
+			if _, ok := in.Peek(1); !ok {
+				break
+			}
+			line, _, err := parse.StringUntil(NewLineOrEOF).Parse(in)
+			if err != nil {
+				return nil, false, err
+			}
+			NewLineOrEOF.Parse(in)
+
+			if strings.TrimSpace(line) == "" {
+				blanks = append(blanks, line)
+				continue
+			}
+			if indentWidth(line) <= indentWidth(indent) || isTask(line, statuses) {
+				break
+			}
+			notes = append(append(notes, blanks...), line)
+			blanks = nil
+			end = in.Index()
+		}
+		in.Seek(end)
+		return notes, len(notes) > 0, nil
+	})
+}
+
+func isTask(line string, statuses *Statuses) bool {
+	in := parse.NewInput(strings.TrimLeft(line, " \t"))
+	if _, ok, _ := listItemOpen.Parse(in); !ok {
+		return false
+	}
+	_, ok, _ := statusParser(statuses).Parse(in)
+	return ok
+}
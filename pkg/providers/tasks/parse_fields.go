@@ -19,6 +19,7 @@ import (
 	"strconv"
 	"strings"
 	"time"
+	"unicode"
 
 	"github.com/a-h/parse"
 	. "github.com/notedownorg/notedown/pkg/parsers"
@@ -34,57 +35,153 @@ var (
 	scheduledKeyShort = parse.String("s:")
 	scheduledKey      = parse.Any(scheduledKeyLong, scheduledKeyShort)
 
+	// Defer is the same as start, the task isn't available until the date
+	startKeyLong  = parse.String("start:")
+	startKeyAlias = parse.String("defer:")
+	startKey      = parse.Any(startKeyLong, startKeyAlias)
+
 	completedKey = parse.Any(parse.String("completed:"))
 
 	everyKeyLong  = parse.String("every:")
 	everyKeyShort = parse.String("e:")
-	everyKey      = parse.Any(everyKeyLong, everyKeyShort)
+
+	// The ! means the recurrence is from completion rather than the due date
+	everyFromCompletionKeyLong  = parse.String("every!:")
+	everyFromCompletionKeyShort = parse.String("e!:")
+	everyKey                    = parse.Any(everyKeyLong, everyKeyShort, everyFromCompletionKeyLong, everyFromCompletionKeyShort)
 
 	priorityKeyLong  = parse.String("priority:")
 	priorityKeyShort = parse.String("p:")
 	priorityKey      = parse.Any(priorityKeyLong, priorityKeyShort)
 
-	anyFieldKey = parse.Any(dueKey, scheduledKey, everyKey, priorityKey, completedKey)
+	idKey    = parse.String("id:")
+	afterKey = parse.String("after:")
+
+	estimateKey = parse.String("estimate:")
+	spentKey    = parse.String("spent:")
+	startedKey  = parse.String("started:")
+
+	anyFieldKey = parse.Any(dueKey, scheduledKey, startKey, everyKey, priorityKey, completedKey, idKey, afterKey, estimateKey, spentKey, startedKey)
 )
 
-var dueParser = parse.Func(func(in *parse.Input) (time.Time, bool, error) {
-	_, longOk, err := dueKeyLong.Parse(in)
-	if err != nil {
-		return time.Time{}, false, err
-	}
-	_, shortOk, err := dueKeyShort.Parse(in)
-	if err != nil {
-		return time.Time{}, false, err
+// Dates can be absolute or relative to relativeTo e.g. tomorrow or +3d
+var dueParser = func(relativeTo time.Time) parse.Parser[Timestamp] {
+	return parse.Func(func(in *parse.Input) (Timestamp, bool, error) {
+		_, longOk, err := dueKeyLong.Parse(in)
+		if err != nil {
+			return Timestamp{}, false, err
+		}
+		_, shortOk, err := dueKeyShort.Parse(in)
+		if err != nil {
+			return Timestamp{}, false, err
+		}
+
+		if !longOk && !shortOk {
+			return Timestamp{}, false, nil
+		}
+		return Date(relativeTo).Parse(in)
+	})
+}
+
+var scheduledParser = func(relativeTo time.Time) parse.Parser[Timestamp] {
+	return parse.Func(func(in *parse.Input) (Timestamp, bool, error) {
+		_, longOk, err := scheduledKeyLong.Parse(in)
+		if err != nil {
+			return Timestamp{}, false, err
+		}
+		_, shortOk, err := scheduledKeyShort.Parse(in)
+		if err != nil {
+			return Timestamp{}, false, err
+		}
+
+		if !longOk && !shortOk {
+			return Timestamp{}, false, nil
+		}
+		return Date(relativeTo).Parse(in)
+	})
+}
+
+var startParser = func(relativeTo time.Time) parse.Parser[Timestamp] {
+	return parse.Func(func(in *parse.Input) (Timestamp, bool, error) {
+		_, ok, err := startKey.Parse(in)
+		if err != nil || !ok {
+			return Timestamp{}, false, err
+		}
+		return Date(relativeTo).Parse(in)
+	})
+}
+
+var completedParser = parse.Func(func(in *parse.Input) (Timestamp, bool, error) {
+	_, ok, err := completedKey.Parse(in)
+	if err != nil || !ok {
+		return Timestamp{}, false, err
 	}
+	return DateTime.Parse(in)
+})
 
-	if !longOk && !shortOk {
-		return time.Time{}, false, nil
+// IDs are made up of letters, numbers, dashes and underscores
+var idValue = parse.StringFrom(parse.AtLeast(1, parse.RuneWhere(func(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '-' || r == '_'
+})))
+
+var idParser = parse.Func(func(in *parse.Input) (string, bool, error) {
+	_, ok, err := idKey.Parse(in)
+	if err != nil || !ok {
+		return "", false, err
 	}
-	return YearMonthDay.Parse(in)
+	return idValue.Parse(in)
 })
 
-var scheduledParser = parse.Func(func(in *parse.Input) (time.Time, bool, error) {
-	_, longOk, err := scheduledKeyLong.Parse(in)
-	if err != nil {
-		return time.Time{}, false, err
+// The IDs of the tasks that must be done first, separated by commas
+var afterParser = parse.Func(func(in *parse.Input) ([]string, bool, error) {
+	_, ok, err := afterKey.Parse(in)
+	if err != nil || !ok {
+		return nil, false, err
 	}
-	_, shortOk, err := scheduledKeyShort.Parse(in)
-	if err != nil {
-		return time.Time{}, false, err
+	first, ok, err := idValue.Parse(in)
+	if err != nil || !ok {
+		return nil, false, err
 	}
-
-	if !longOk && !shortOk {
-		return time.Time{}, false, nil
+	ids := []string{first}
+	for {
+		start := in.Index()
+		_, ok, err := parse.Rune(',').Parse(in)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			break
+		}
+		id, ok, err := idValue.Parse(in)
+		if err != nil {
+			return nil, false, err
+		}
+		if !ok {
+			in.Seek(start)
+			break
+		}
+		ids = append(ids, id)
 	}
-	return YearMonthDay.Parse(in)
+	return ids, true, nil
 })
 
-var completedParser = parse.Func(func(in *parse.Input) (time.Time, bool, error) {
-	_, ok, err := completedKey.Parse(in)
+// Durations are written in hours and/or minutes e.g. estimate:1h30m
+var durationParser = func(key parse.Parser[string]) parse.Parser[time.Duration] {
+	return parse.Func(func(in *parse.Input) (time.Duration, bool, error) {
+		_, ok, err := key.Parse(in)
+		if err != nil || !ok {
+			return 0, false, err
+		}
+		return Duration.Parse(in)
+	})
+}
+
+var startedParser = parse.Func(func(in *parse.Input) (Timestamp, bool, error) {
+	_, ok, err := startedKey.Parse(in)
 	if err != nil || !ok {
-		return time.Time{}, false, err
+		return Timestamp{}, false, err
 	}
-	return YearMonthDay.Parse(in)
+	return DateTime.Parse(in)
 })
 
 var priorityParser = parse.Func(func(in *parse.Input) (int, bool, error) {
@@ -113,46 +210,112 @@ var priorityParser = parse.Func(func(in *parse.Input) (int, bool, error) {
 	return p, true, nil
 })
 
+const fromCompletion = " from completion"
+
 var everyParser = func(relativeTo time.Time) parse.Parser[Every] {
 	return parse.Func(func(in *parse.Input) (Every, bool, error) {
-		_, longOk, err := everyKeyLong.Parse(in)
-		if err != nil {
+		key, ok, err := everyKey.Parse(in)
+		if err != nil || !ok {
 			return Every{}, false, err
 		}
-		_, shortOk, err := everyKeyShort.Parse(in)
-		if err != nil {
+
+		every, ok, err := everyRule(relativeTo).Parse(in)
+		if err != nil || !ok {
 			return Every{}, false, err
 		}
+		every.bang = strings.Contains(key, "!")
+		every.fromCompletion = every.bang
 
-		if !longOk && !shortOk {
-			return Every{}, false, nil
+		start := in.Index()
+		_, ok, err = parse.StringFrom(RemainingInlineWhitespace, parse.String(strings.TrimSpace(fromCompletion))).Parse(in)
+		if err != nil {
+			return Every{}, false, err
 		}
+		if !ok {
+			in.Seek(start)
+			return every, true, nil
+		}
+		every.fromCompletion = true
+		every.text += fromCompletion
+		return every, true, nil
+	})
+}
 
-		rruleOpts := rrule.ROption{Dtstart: relativeTo}
+// The recurrence rule following the every: key
+var everyRule = func(relativeTo time.Time) parse.Parser[Every] {
+	return parse.Func(func(in *parse.Input) (Every, bool, error) {
+		// Occurrences are dates so the time of day we happen to be parsing at shouldn't leak into them
+		rruleOpts := rrule.ROption{Dtstart: day(relativeTo)}
+
+		// These closures keep track of where we started so we can store the original text.
+		start := in.Index()
+		text := func() (string, error) {
+			end := in.Index()
+			in.Seek(start)
+			text, ok := in.Take(end - start)
+			if !ok {
+				return "", fmt.Errorf("failed to store original every text start: %d end: %d", start, end)
+			}
+			return strings.TrimSpace(text), nil
+		}
+		buildResult := func(opts rrule.ROption, err error) (Every, bool, error) {
+			if err != nil {
+				return Every{}, false, err
+			}
+			text, err := text()
+			if err != nil {
+				return Every{}, false, err
+			}
+			rr, err := rrule.NewRRule(opts)
+			if err != nil {
+				// Keep rules that can't be built (e.g. every:32nd) so they're written back out as is and can be reported
+				return Every{text: text, err: fmt.Errorf("invalid recurrence: %w", err)}, true, nil
+			}
+			return Every{rrule: rr, text: text, err: neverOccurs(opts)}, true, nil
+		}
 
-		// This closure keeps track of where we started so we can store the original text.
-		buildResult := func() func(rrule.ROption, error) (Every, bool, error) {
-			start := in.Index()
-			return func(opts rrule.ROption, err error) (Every, bool, error) {
-				if err != nil {
-					return Every{}, false, err
-				}
-				rr, err := rrule.NewRRule(opts)
-				if err != nil {
-					return Every{}, false, err
+		// A raw RFC 5545 rule e.g. RRULE:FREQ=MONTHLY;BYDAY=FR;BYSETPOS=-1
+		raw, ok, err := rawRRule.Parse(in)
+		if err != nil {
+			return buildResult(rruleOpts, err)
+		}
+		if ok {
+			opts, err := rrule.StrToROption(raw)
+			if err != nil {
+				text, terr := text()
+				if terr != nil {
+					return Every{}, false, terr
 				}
+				return Every{text: text, err: fmt.Errorf("invalid recurrence: %w", err)}, true, nil
+			}
+			if opts.Dtstart.IsZero() {
+				opts.Dtstart = rruleOpts.Dtstart
+			}
+			return buildResult(withLimits(in, *opts))
+		}
 
-				// Get the text
-				end := in.Index()
-				in.Seek(start)
-				text, ok := in.Take(end - start)
-				if !ok {
-					return Every{}, false, fmt.Errorf("failed to store original every text start: %d end: %d", start, end)
-				}
+		// Every last day of the month
+		_, ok, err = lastDayOfMonth.Parse(in)
+		if err != nil {
+			return buildResult(rruleOpts, err)
+		}
+		if ok {
+			rruleOpts.Freq = rrule.MONTHLY
+			rruleOpts.Bymonthday = []int{-1}
+			return buildResult(withLimits(in, rruleOpts))
+		}
 
-				return Every{rrule: rr, text: strings.TrimSpace(text)}, true, nil
-			}
-		}()
+		// Every <ordinal> <day of week> of the month e.g. 2nd tuesday or last friday
+		nth, ok, err := ordinalWeekday.Parse(in)
+		if err != nil {
+			return buildResult(rruleOpts, err)
+		}
+		if ok {
+			rruleOpts.Freq = rrule.MONTHLY
+			rruleOpts.Byweekday = []rrule.Weekday{rruleDayOfWeek(nth.B)}
+			rruleOpts.Bysetpos = []int{nth.A}
+			return buildResult(withLimits(in, rruleOpts))
+		}
 
 		// There are a limited number of single words that can be used to describe the frequency.
 		// So lets get those out of the way first. (day, week, month, year, weekday, weekend)
@@ -178,7 +341,7 @@ var everyParser = func(relativeTo time.Time) parse.Parser[Every] {
 				rruleOpts.Byweekday = []rrule.Weekday{rrule.SA}
 				rruleOpts.Freq = rrule.WEEKLY
 			}
-			return buildResult(rruleOpts, nil)
+			return buildResult(withLimits(in, rruleOpts))
 		}
 
 		// Every <day of week> or list of <day of week>
@@ -191,7 +354,7 @@ var everyParser = func(relativeTo time.Time) parse.Parser[Every] {
 				rruleOpts.Byweekday = append(rruleOpts.Byweekday, rruleDayOfWeek(d))
 			}
 			rruleOpts.Freq = rrule.WEEKLY
-			return buildResult(rruleOpts, nil)
+			return buildResult(withLimits(in, rruleOpts))
 		}
 
 		// Every <number> <day/week/month/year>
@@ -216,7 +379,7 @@ var everyParser = func(relativeTo time.Time) parse.Parser[Every] {
 				rruleOpts.Freq = rrule.YEARLY
 			}
 			rruleOpts.Interval, _ = strconv.Atoi(n)
-			return buildResult(rruleOpts, nil)
+			return buildResult(withLimits(in, rruleOpts))
 		}
 
 		// Some combination of month days and/or months
@@ -250,13 +413,126 @@ var everyParser = func(relativeTo time.Time) parse.Parser[Every] {
 			if len(rruleOpts.Bymonthday) == 0 {
 				rruleOpts.Bymonthday = append(rruleOpts.Bymonthday, 1)
 			}
-			return buildResult(rruleOpts, nil)
+			return buildResult(withLimits(in, rruleOpts))
 		}
 
 		return Every{}, false, nil
 	})
 }
 
+// Rules that are valid but can never fire (e.g. every:31st feb) are reported rather than silently accepted.
+// Limits are ignored as a rule that has run its course is still a valid rule.
+func neverOccurs(opts rrule.ROption) error {
+	opts.Count, opts.Until = 0, time.Time{}
+	rr, err := rrule.NewRRule(opts)
+	if err != nil {
+		return fmt.Errorf("invalid recurrence: %w", err)
+	}
+	if rr.After(opts.Dtstart, true).IsZero() {
+		return fmt.Errorf("recurrence never occurs")
+	}
+	return nil
+}
+
+// Raw rules run until the next whitespace as they can't contain spaces
+var rawRRule = parse.StringFrom(
+	parse.Any(parse.String("RRULE:"), parse.String("FREQ=")),
+	parse.StringFrom(parse.ZeroOrMore(parse.RuneWhere(func(r rune) bool { return !unicode.IsSpace(r) }))),
+)
+
+var lastDayOfMonth = parse.StringFrom(
+	parse.String("last day"),
+	parse.StringFrom(parse.Optional(parse.Any(parse.String(" of the month"), parse.String(" of month")))),
+)
+
+// 1st-5th, first-fifth or last (as -1)
+var ordinal = parse.Func(func(in *parse.Input) (int, bool, error) {
+	words := []struct {
+		n      int
+		parser parse.Parser[string]
+	}{
+		{1, parse.Any(parse.String("first"), parse.String("1st"))},
+		{2, parse.Any(parse.String("second"), parse.String("2nd"))},
+		{3, parse.Any(parse.String("third"), parse.String("3rd"))},
+		{4, parse.Any(parse.String("fourth"), parse.String("4th"))},
+		{5, parse.Any(parse.String("fifth"), parse.String("5th"))},
+		{-1, parse.String("last")},
+	}
+	for _, word := range words {
+		_, ok, err := word.parser.Parse(in)
+		if err != nil {
+			return 0, false, err
+		}
+		if ok {
+			return word.n, true, nil
+		}
+	}
+	return 0, false, nil
+})
+
+var ordinalWeekday = parse.Func(func(in *parse.Input) (parse.Tuple2[int, time.Weekday], bool, error) {
+	start := in.Index()
+	res, ok, err := parse.SequenceOf3(ordinal, parse.Rune(' '), DayOfWeek).Parse(in)
+	if err != nil || !ok {
+		in.Seek(start)
+		return parse.Tuple2[int, time.Weekday]{}, false, err
+	}
+	// Allow (but don't require) the month to be spelled out
+	_, _, err = parse.Any(parse.String(" of the month"), parse.String(" of month")).Parse(in)
+	if err != nil {
+		return parse.Tuple2[int, time.Weekday]{}, false, err
+	}
+	return parse.Tuple2[int, time.Weekday]{A: res.A, B: res.C}, true, nil
+})
+
+// Reads the optional "until <date>" and "for <n> times" suffixes that limit a recurrence, in either order.
+// Anything else (including the whitespace before it) is left for the other field parsers.
+func withLimits(in *parse.Input, opts rrule.ROption) (rrule.ROption, error) {
+	until := parse.StringFrom(RemainingInlineWhitespace, parse.String("until "))
+	count := parse.SequenceOf4(
+		parse.StringFrom(RemainingInlineWhitespace, parse.String("for ")),
+		parse.StringFrom(parse.AtLeast(1, parse.ZeroToNine)),
+		parse.Rune(' '),
+		parse.Any(parse.String("times"), parse.String("time")),
+	)
+	for {
+		start := in.Index()
+
+		_, ok, err := until.Parse(in)
+		if err != nil {
+			return opts, err
+		}
+		if ok && opts.Until.IsZero() {
+			ts, ok, err := DateTime.Parse(in)
+			if err != nil {
+				return opts, err
+			}
+			if ok {
+				opts.Until = ts.Time
+				if !ts.HasTime() {
+					opts.Until = ts.End()
+				}
+				continue
+			}
+		}
+		in.Seek(start)
+
+		res, ok, err := count.Parse(in)
+		if err != nil {
+			return opts, err
+		}
+		if ok && opts.Count == 0 {
+			opts.Count, err = strconv.Atoi(res.B)
+			if err != nil {
+				return opts, fmt.Errorf("invalid recurrence count: %w", err)
+			}
+			continue
+		}
+		in.Seek(start)
+		return opts, nil
+	}
+}
+
 func rruleDayOfWeek(d time.Weekday) rrule.Weekday {
 	switch d {
 	case time.Sunday:
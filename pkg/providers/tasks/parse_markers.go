@@ -0,0 +1,69 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"strings"
+	"unicode"
+)
+
+const (
+	tagPrefix     = '#'
+	contextPrefix = '@'
+	projectPrefix = '+'
+)
+
+// Find the markers with the given prefix in the text, in the order they first appear and without the prefix.
+// A marker must start a word and contain at least one letter (so #1 or +1 aren't markers), it ends at the first
+// character that isn't a letter, number, underscore, dash or slash.
+func parseMarkers(prefix rune, text string) []string {
+	var res []string
+	seen := make(map[string]bool)
+	for _, word := range strings.Fields(text) {
+		marker, ok := strings.CutPrefix(word, string(prefix))
+		if !ok {
+			continue
+		}
+		if end := strings.IndexFunc(marker, func(r rune) bool { return !isMarkerRune(r) }); end != -1 {
+			marker = marker[:end]
+		}
+		if !strings.ContainsFunc(marker, unicode.IsLetter) {
+			continue
+		}
+		if !seen[strings.ToLower(marker)] {
+			seen[strings.ToLower(marker)] = true
+			res = append(res, marker)
+		}
+	}
+	return res
+}
+
+func isMarkerRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_' || r == '-' || r == '/'
+}
+
+// Markers written after the task's fields, these aren't part of the name so need to be kept separately
+func trailingMarkers(text string) []string {
+	var res []string
+	for _, word := range strings.Fields(text) {
+		for _, prefix := range []rune{tagPrefix, contextPrefix, projectPrefix} {
+			if len(parseMarkers(prefix, word)) > 0 {
+				res = append(res, word)
+				break
+			}
+		}
+	}
+	return res
+}
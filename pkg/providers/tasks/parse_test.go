@@ -15,10 +15,12 @@
 package tasks
 
 import (
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/a-h/parse"
+	. "github.com/notedownorg/notedown/pkg/parsers"
 	"github.com/stretchr/testify/assert"
 	"github.com/teambition/rrule-go"
 )
@@ -83,6 +85,54 @@ func TestParseTask(t *testing.T) {
 			input:    "- [A] Task",
 			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Abandoned),
 		},
+		{
+			name:     "Waiting (lowercase)",
+			input:    "- [w] Task",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Waiting),
+		},
+		{
+			name:     "Waiting (uppercase)",
+			input:    "- [W] Task",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Waiting),
+		},
+		// Times
+		{
+			name:     "Due with a time",
+			input:    "- [ ] Call due:2021-01-01 14:00 scheduled:2021-01-01T09:30+01:00",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Call", Todo, withDue(Timestamp{Time: time.Date(2021, 1, 1, 14, 0, 0, 0, time.UTC), Layout: "2006-01-02 15:04"}), withScheduled(Timestamp{Time: time.Date(2021, 1, 1, 9, 30, 0, 0, time.FixedZone("", 3600)), Layout: "2006-01-02T15:04Z07:00"})),
+		},
+		{
+			name:     "Relative dates",
+			input:    "- [ ] Task due:tomorrow s:in 2 weeks p:1",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithDue(date(2020, 1, 3)), WithScheduled(date(2020, 1, 16)), WithPriority(1)),
+		},
+		// Dependencies
+		{
+			name:     "ID",
+			input:    "- [ ] Task id:task-1",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithID("task-1")),
+		},
+		{
+			name:     "After",
+			input:    "- [ ] Task after:a,b_2 id:c",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithID("c"), WithAfter("a", "b_2")),
+		},
+		{
+			name:     "After with a trailing comma",
+			input:    "- [ ] Task after:a,",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithAfter("a")),
+		},
+		// Markers
+		{
+			name:     "Markers in the name",
+			input:    "- [ ] Call #mum @phone +family",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Call #mum @phone +family", Todo),
+		},
+		{
+			name:     "Markers after the fields",
+			input:    "- [ ] Call #mum due:2021-01-01 @phone +family",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Call #mum", Todo, WithDue(date(2021, 1, 1)), withTrailing([]string{"@phone", "+family"})),
+		},
 		// Whitespace tests
 		{
 			name:     "Leading space",
@@ -132,6 +182,41 @@ func TestParseTask(t *testing.T) {
 			expected:      NewTask(NewIdentifier("path", "version", 1), "Task 1", Todo),
 			leftOverInput: true,
 		},
+		{
+			name:     "Start date",
+			input:    "- [ ] Task start:2021-01-01 due:2021-01-05",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithStart(date(2021, 1, 1)), WithDue(date(2021, 1, 5))),
+		},
+		{
+			name:     "Defer date",
+			input:    "- [ ] Task defer:2021-01-01",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithStart(date(2021, 1, 1))),
+		},
+		{
+			name:     "Estimate and spent",
+			input:    "- [ ] Task estimate:1h30m spent:45m",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithEstimate(90*time.Minute), WithSpent(45*time.Minute)),
+		},
+		{
+			name:     "Started",
+			input:    "- [/] Task spent:2h started:2021-01-01T09:00",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Doing, WithSpent(2*time.Hour), WithStarted(time.Date(2021, 1, 1, 9, 0, 0, 0, time.UTC))),
+		},
+		{
+			name:     "Invalid estimate",
+			input:    "- [ ] Task estimate:soon",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo),
+		},
+		{
+			name:     "Out of range estimate",
+			input:    "- [ ] Task estimate:99999999999999999999h spent:45m priority:1",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithSpent(45*time.Minute), WithPriority(1)),
+		},
+		{
+			name:     "Out of range relative date",
+			input:    "- [ ] Task due:+99999999999999999999d priority:1",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithPriority(1)),
+		},
 		{
 			name:     "Completed date",
 			input:    "- [ ] Task completed:2021-01-01",
@@ -145,7 +230,22 @@ func TestParseTask(t *testing.T) {
 		{
 			name:     "Every",
 			input:    "- [ ] Task every:day",
-			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithEvery(Every{dailyRule, "day"})),
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithEvery(Every{rrule: dailyRule, text: "day"})),
+		},
+		{
+			name:     "Every from completion",
+			input:    "- [ ] Task every!:day",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithEvery(Every{rrule: dailyRule, text: "day", fromCompletion: true, bang: true})),
+		},
+		{
+			name:     "Every from completion short key",
+			input:    "- [ ] Task e!:day",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithEvery(Every{rrule: dailyRule, text: "day", fromCompletion: true, bang: true})),
+		},
+		{
+			name:     "Every from completion suffix",
+			input:    "- [ ] Task every:day from completion due:2021-01-01",
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithDue(date(2021, 1, 1)), WithEvery(Every{rrule: dailyRule, text: "day from completion", fromCompletion: true})),
 		},
 		{
 			name:          "Completed date on different task",
@@ -161,12 +261,12 @@ func TestParseTask(t *testing.T) {
 		{
 			name:     "Fields parse order",
 			input:    "- [ ] Task due:2021-01-01 scheduled:2021-01-02 completed:2021-01-03 priority:1 every:day",
-			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithDue(date(2021, 1, 1)), WithScheduled(date(2021, 1, 2)), WithCompleted(date(2021, 1, 3)), WithPriority(1), WithEvery(Every{dailyRule, "day"})),
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithDue(date(2021, 1, 1)), WithScheduled(date(2021, 1, 2)), WithCompleted(date(2021, 1, 3)), WithPriority(1), WithEvery(Every{rrule: dailyRule, text: "day"})),
 		},
 		{
 			name:     "Fields reverse parse order",
 			input:    "- [ ] Task every:day priority:1 completed:2021-01-03 scheduled:2021-01-02 due:2021-01-01",
-			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithDue(date(2021, 1, 1)), WithScheduled(date(2021, 1, 2)), WithCompleted(date(2021, 1, 3)), WithPriority(1), WithEvery(Every{dailyRule, "day"})),
+			expected: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithDue(date(2021, 1, 1)), WithScheduled(date(2021, 1, 2)), WithCompleted(date(2021, 1, 3)), WithPriority(1), WithEvery(Every{rrule: dailyRule, text: "day"})),
 		},
 	}
 	for _, test := range tests {
@@ -176,6 +276,9 @@ func TestParseTask(t *testing.T) {
 			if !found {
 				t.Fatal("expected found")
 			}
+			source := strings.Split(test.input, "\n")[0]
+			withSource(source)(&test.expected) // the task always tracks the line it was parsed from
+			WithIndent(source[:len(source)-len(strings.TrimLeft(source, " \t"))])(&test.expected)
 			assert.Equal(t, test.expected, result)
 			if test.leftOverInput {
 				assert.NotEqual(t, len(test.input), in.Index(), "expected there to be leftover input")
@@ -186,6 +289,108 @@ func TestParseTask(t *testing.T) {
 	}
 }
 
+func TestParseTaskNotes(t *testing.T) {
+	tests := []struct {
+		name      string
+		input     string
+		notes     []string
+		remaining string
+	}{
+		{
+			name:      "No notes",
+			input:     "- [ ] Task\nText",
+			remaining: "Text",
+		},
+		{
+			name:  "Paragraph",
+			input: "- [ ] Task\n  Some detail\n  [a link](https://example.com)",
+			notes: []string{"  Some detail", "  [a link](https://example.com)"},
+		},
+		{
+			name:      "Bullet notes stop at the next task",
+			input:     "- [ ] Task\n  - a bullet\n\t- a tabbed bullet\n- [ ] Next",
+			notes:     []string{"  - a bullet", "\t- a tabbed bullet"},
+			remaining: "- [ ] Next",
+		},
+		{
+			name:      "Subtasks aren't notes",
+			input:     "- [ ] Task\n  - [ ] Subtask",
+			remaining: "  - [ ] Subtask",
+		},
+		{
+			name:      "Blank lines between notes",
+			input:     "- [ ] Task\n  One\n\n  Two\n\nText",
+			notes:     []string{"  One", "", "  Two"},
+			remaining: "\nText",
+		},
+		{
+			name:      "Notes must be indented further than the task",
+			input:     "  - [ ] Task\n  Not a note",
+			remaining: "  Not a note",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			in := parse.NewInput(test.input)
+			result, found, err := ParseTask("path", "version", relativeTo).Parse(in)
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, test.notes, result.Notes())
+			remaining, _ := in.Peek(-1)
+			assert.Equal(t, test.remaining, remaining)
+		})
+	}
+}
+
+func TestParseMarkers(t *testing.T) {
+	tests := []struct {
+		name     string
+		input    string
+		tags     []string
+		contexts []string
+		projects []string
+		body     string
+	}{
+		{
+			name:     "Anywhere in the task",
+			input:    "- [ ] #urgent Call @phone about +house due:2021-01-01 #admin",
+			tags:     []string{"urgent", "admin"},
+			contexts: []string{"phone"},
+			projects: []string{"house"},
+			body:     "#urgent Call @phone about +house due:2021-01-01 #admin",
+		},
+		{
+			name:  "Duplicates are only returned once",
+			input: "- [ ] #work and #Work again",
+			tags:  []string{"work"},
+			body:  "#work and #Work again",
+		},
+		{
+			name:     "Trailing punctuation",
+			input:    "- [ ] Ask @sam, then #follow-up/later.",
+			tags:     []string{"follow-up/later"},
+			contexts: []string{"sam"},
+			body:     "Ask @sam, then #follow-up/later.",
+		},
+		{
+			name:  "Not markers",
+			input: "- [ ] Fix issue #12 for me@example.com a+b +1",
+			body:  "Fix issue #12 for me@example.com a+b +1",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task, found, err := ParseTask("path", "version", relativeTo).Parse(parse.NewInput(test.input))
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, test.tags, task.Tags())
+			assert.Equal(t, test.contexts, task.Contexts())
+			assert.Equal(t, test.projects, task.Projects())
+			assert.Equal(t, test.body, task.Body())
+		})
+	}
+}
+
 func TestParseDueDate(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -203,15 +408,20 @@ func TestParseDueDate(t *testing.T) {
 			input:    "d:2021-01-01",
 			expected: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
 		},
+		{
+			name:     "With time",
+			input:    "due:2021-01-01T14:00",
+			expected: time.Date(2021, 1, 1, 14, 0, 0, 0, time.UTC),
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			in := parse.NewInput(test.input)
-			result, found, _ := dueParser.Parse(in)
+			result, found, _ := dueParser(relativeTo).Parse(in)
 			if !found {
 				t.Fatal("expected found")
 			}
-			assert.Equal(t, test.expected, result)
+			assert.Equal(t, test.expected, result.Time)
 		})
 	}
 }
@@ -236,11 +446,11 @@ func TestParseScheduledDate(t *testing.T) {
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
 			in := parse.NewInput(test.input)
-			result, found, _ := scheduledParser.Parse(in)
+			result, found, _ := scheduledParser(relativeTo).Parse(in)
 			if !found {
 				t.Fatal("expected found")
 			}
-			assert.Equal(t, test.expected, result)
+			assert.Equal(t, test.expected, result.Time)
 		})
 	}
 }
@@ -264,7 +474,7 @@ func TestParseCompletedDate(t *testing.T) {
 			if !found {
 				t.Fatal("expected found")
 			}
-			assert.Equal(t, test.expected, result)
+			assert.Equal(t, test.expected, result.Time)
 		})
 	}
 }
@@ -317,6 +527,99 @@ func TestParsePriority(t *testing.T) {
 	}
 }
 
+func TestParseEveryRoundTrip(t *testing.T) {
+	tests := []struct {
+		input          string
+		fromCompletion bool
+		body           string
+	}{
+		{input: "- [ ] Task every:week", body: "Task every:week"},
+		{input: "- [ ] Task e:week", body: "Task e:week"},
+		{input: "- [ ] Task every!:week", fromCompletion: true, body: "Task every!:week"},
+		{input: "- [ ] Task e!:2 days", fromCompletion: true, body: "Task e!:2 days"},
+		{input: "- [ ] Task every:week from completion", fromCompletion: true, body: "Task every:week from completion"},
+		{input: "- [ ] Task every:weekday until 2025-06-30 due:2021-01-01", body: "Task every:weekday until 2025-06-30 due:2021-01-01"},
+		{input: "- [ ] Task e:last friday for 6 times from completion p:1", fromCompletion: true, body: "Task e:last friday for 6 times from completion p:1"},
+		{input: "- [ ] Task every:tue thu until 2025-06-30T17:00 for 3 times", body: "Task every:tue thu until 2025-06-30T17:00 for 3 times"},
+		{input: "- [ ] Task every:RRULE:FREQ=MONTHLY;BYMONTHDAY=-1 #admin", body: "Task every:RRULE:FREQ=MONTHLY;BYMONTHDAY=-1 #admin"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			task, found, err := ParseTask("path", "version", relativeTo).Parse(parse.NewInput(test.input))
+			assert.NoError(t, err)
+			assert.True(t, found)
+			if assert.NotNil(t, task.Every()) {
+				assert.Equal(t, test.fromCompletion, task.Every().FromCompletion())
+
+				// The recurrence can be recreated from its string without losing when it recurs from
+				every, err := NewEvery(task.Every().String())
+				assert.NoError(t, err)
+				assert.Equal(t, test.fromCompletion, every.FromCompletion())
+				assert.Equal(t, task.Every().String(), every.String())
+			}
+			assert.Equal(t, test.body, task.Body())
+		})
+	}
+}
+
+func TestParseTaskRoundTrip(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		options []TaskOption
+		body    string
+	}{
+		{
+			name:  "Unchanged",
+			input: "- [ ] call bob  due:2024-01-01 about invoice foo:bar p:1 #work",
+			body:  "call bob  due:2024-01-01 about invoice foo:bar p:1 #work",
+		},
+		{
+			name:    "Changed field keeps its key and position",
+			input:   "- [ ] call bob d:2024-01-01 about invoice defer:2023-12-01",
+			options: []TaskOption{WithDue(date(2024, 1, 5)), WithStart(date(2023, 12, 2))},
+			body:    "call bob d:2024-01-05 about invoice defer:2023-12-02",
+		},
+		{
+			name:    "Removed field",
+			input:   "- [ ] call bob id:abc about invoice",
+			options: []TaskOption{WithID("")},
+			body:    "call bob about invoice",
+		},
+		{
+			name:    "New fields are added to the end",
+			input:   "- [ ] call bob d:2024-01-01 about invoice #work",
+			options: []TaskOption{WithCompleted(date(2024, 1, 2)), WithPriority(2)},
+			body:    "call bob d:2024-01-01 about invoice #work priority:2 completed:2024-01-02",
+		},
+		{
+			name:    "Renamed",
+			input:   "- [ ] call bob p:1 about invoice",
+			options: []TaskOption{WithName("email bob")},
+			body:    "email bob p:1 about invoice",
+		},
+		{
+			name:  "Relative dates are made absolute",
+			input: "- [ ] call bob s:tomorrow about invoice",
+			body:  "call bob s:2020-01-03 about invoice",
+		},
+		{
+			name:    "Only the first of a repeated field is read",
+			input:   "- [ ] call bob p:1 p:2",
+			options: []TaskOption{WithPriority(3)},
+			body:    "call bob p:3 p:2",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			task, found, err := ParseTask("path", "version", relativeTo).Parse(parse.NewInput(test.input))
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, test.body, NewTaskFromTask(task, test.options...).Body())
+		})
+	}
+}
+
 func TestParseEvery(t *testing.T) {
 	tests := []struct {
 		input        string
@@ -596,6 +899,87 @@ func TestParseEvery(t *testing.T) {
 			},
 			end: time.Date(2022, 2, 1, 0, 0, 0, 0, time.UTC),
 		},
+		{
+			input:        "every:2nd tuesday",
+			expectedText: "2nd tuesday",
+			expected: []time.Time{
+				time.Date(2020, 1, 14, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 2, 11, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 3, 10, 0, 0, 0, 0, time.UTC),
+			},
+			end: time.Date(2020, 3, 10, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			input:        "every:1st mon",
+			expectedText: "1st mon",
+			expected: []time.Time{
+				time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 2, 3, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC),
+			},
+			end: time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			input:        "every:last fri",
+			expectedText: "last fri",
+			expected: []time.Time{
+				time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 2, 28, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 3, 27, 0, 0, 0, 0, time.UTC),
+			},
+			end: time.Date(2020, 3, 27, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			input:        "every:last day of the month",
+			expectedText: "last day of the month",
+			expected: []time.Time{
+				time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 2, 29, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 3, 31, 0, 0, 0, 0, time.UTC),
+			},
+			end: time.Date(2020, 3, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			input:        "every:weekday until 2020-01-08",
+			expectedText: "weekday until 2020-01-08",
+			expected: []time.Time{
+				time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 1, 3, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 1, 7, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 1, 8, 0, 0, 0, 0, time.UTC),
+			},
+			end: time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			input:        "every:month for 3 times",
+			expectedText: "month for 3 times",
+			expected: []time.Time{
+				time.Date(2020, 1, 2, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 2, 2, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 3, 2, 0, 0, 0, 0, time.UTC),
+			},
+			end: time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			input:        "every:mon for 2 times until 2020-12-31",
+			expectedText: "mon for 2 times until 2020-12-31",
+			expected: []time.Time{
+				time.Date(2020, 1, 6, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 1, 13, 0, 0, 0, 0, time.UTC),
+			},
+			end: time.Date(2020, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			input:        "every:RRULE:FREQ=MONTHLY;BYDAY=FR;BYSETPOS=-1",
+			expectedText: "RRULE:FREQ=MONTHLY;BYDAY=FR;BYSETPOS=-1",
+			expected: []time.Time{
+				time.Date(2020, 1, 31, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 2, 28, 0, 0, 0, 0, time.UTC),
+				time.Date(2020, 3, 27, 0, 0, 0, 0, time.UTC),
+			},
+			end: time.Date(2020, 3, 27, 0, 0, 0, 0, time.UTC),
+		},
 	}
 	for _, test := range tests {
 		t.Run(test.input, func(t *testing.T) {
@@ -0,0 +1,418 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/a-h/parse"
+	"github.com/notedownorg/notedown/internal/glob"
+	"github.com/notedownorg/notedown/pkg/parsers"
+	"github.com/notedownorg/notedown/pkg/providers/pkg/collections"
+)
+
+// A query compiled into the filters, sorters and limit to list tasks with, see ParseQuery for the syntax
+type Query struct {
+	Filters []collections.Filter[Task]
+	Sorters []collections.Sorter[Task]
+	// The maximum number of tasks to list, zero for no limit
+	Limit int
+}
+
+// The options to pass to Client.ListTasks. Tasks are always sorted so the same tasks are kept by the limit.
+func (q Query) Options() []collections.ListOption[Task] {
+	opts := []collections.ListOption[Task]{WithFilters(q.Filters...), WithSorters(q.Sorters...)}
+	if q.Limit > 0 {
+		opts = append(opts, collections.Limit[Task](q.Limit))
+	}
+	return opts
+}
+
+// Where a query is invalid and why
+type QueryError struct {
+	// 1-indexed column (in runes) of the problem
+	Column  int
+	Message string
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("invalid query at column %d: %s", e.Column, e.Message)
+}
+
+// Parse a query with the default statuses, relative dates are relative to the given time.
+//
+// A query is a list of terms separated by whitespace, all of which must match. A term is either a word that must be
+// in the task's name or a key, an operator and a value. Fields can also be given by their short keys e.g. p:1.
+// Values containing whitespace (or words containing a colon) can be quoted e.g. due<="next friday". Terms (other than
+// sort and limit) can be negated with a leading dash e.g. -tag:someday.
+//
+//	status:todo,doing          any of the statuses (by name)
+//	category:open,active       any of the status categories
+//	priority:1,2 priority<=2   priorities can also be compared with <, <=, > and >=
+//	due<=+7d                   due, scheduled, start and completed dates can be compared in the same way
+//	path:projects/**           the document path, * matches within a directory and ** across directories
+//	tag:work,home              any of the tags, the same goes for context and project
+//	is:ready                   ready, available (not deferred), leaf or toplevel tasks
+//	sort:priority,-due         priority, due, scheduled or status, a dash reverses the order (tasks without the
+//	                           field are always last)
+//	limit:20                   at most 20 tasks
+func ParseQuery(query string, relativeTo time.Time) (Query, error) {
+	return parseQuery(query, relativeTo, defaultStatuses)
+}
+
+// List the tasks matching the query, see ParseQuery for the syntax
+func (c *Client) Query(query string) ([]Task, error) {
+	q, err := parseQuery(query, time.Now(), c.statuses)
+	if err != nil {
+		return nil, err
+	}
+	return c.ListTasks(FetchAllTasks(), q.Options()...), nil
+}
+
+type queryTerm struct {
+	negate bool
+	// Empty for words matched against the name
+	key, operator, value string
+	// Byte offsets of the start of the term and of its value
+	start, valueStart int
+}
+
+func parseQuery(query string, relativeTo time.Time, statuses *Statuses) (Query, error) {
+	terms, err := lexQuery(query)
+	if err != nil {
+		return Query{}, err
+	}
+	var q Query
+	for _, term := range terms {
+		errorAt := func(offset int, format string, args ...any) error {
+			return &QueryError{Column: utf8.RuneCountInString(query[:offset]) + 1, Message: fmt.Sprintf(format, args...)}
+		}
+		if strings.Trim(term.value, ", ") == "" && term.key != "" {
+			return Query{}, errorAt(term.valueStart, "expected a value after '%s%s'", term.key, term.operator)
+		}
+		if term.operator != ":" && term.operator != "=" && term.operator != "" && !slices.Contains(comparableKeys, term.key) {
+			return Query{}, errorAt(term.start, "'%s' can't be compared with '%s', use '%s:'", term.key, term.operator, term.key)
+		}
+		if term.negate && (term.key == "sort" || term.key == "limit") {
+			return Query{}, errorAt(term.start, "'%s' can't be negated", term.key)
+		}
+
+		var filter collections.Filter[Task]
+		switch term.key {
+		case "":
+			filter = func(t Task) bool { return strings.Contains(strings.ToLower(t.Name()), strings.ToLower(term.value)) }
+
+		case "status":
+			var match []Status
+			for _, item := range splitList(term) {
+				definition, ok := statusByName(statuses, item.value)
+				if !ok {
+					return Query{}, errorAt(item.start, "unknown status '%s'", item.value)
+				}
+				match = append(match, definition.Status)
+			}
+			filter = FilterByStatus(match...)
+
+		case "category":
+			var match []Category
+			for _, item := range splitList(term) {
+				category := Category(strings.ToLower(item.value))
+				if category != Open && category != Active && category != Closed {
+					return Query{}, errorAt(item.start, "unknown category '%s', expected open, active or closed", item.value)
+				}
+				match = append(match, category)
+			}
+			filter = FilterByCategory(match...)
+
+		case "priority":
+			var match []int
+			for _, item := range splitList(term) {
+				priority, err := strconv.Atoi(item.value)
+				if err != nil {
+					return Query{}, errorAt(item.start, "invalid priority '%s', expected a number", item.value)
+				}
+				match = append(match, priority)
+			}
+			if term.operator == ":" || term.operator == "=" {
+				filter = FilterByPriority(match...)
+				break
+			}
+			if len(match) > 1 {
+				return Query{}, errorAt(term.valueStart, "can't compare priority with more than one value")
+			}
+			filter = comparePriority(term.operator, match[0])
+
+		case "due", "scheduled", "start", "completed":
+			in := parse.NewInput(term.value)
+			date, ok, err := parsers.Date(relativeTo).Parse(in)
+			if err != nil || !ok || in.Index() != len(term.value) {
+				return Query{}, errorAt(term.valueStart, "invalid date '%s'", term.value)
+			}
+			after, before := dateRange(term.operator, date)
+			get := dateFields[term.key]
+			filter = func(t Task) bool { return inRange(get(t), after, before) }
+
+		case "path":
+			if err := glob.Validate(term.value); err != nil {
+				return Query{}, errorAt(term.valueStart, "invalid path '%s': %v", term.value, err)
+			}
+			pattern := term.value
+			filter = func(t Task) bool {
+				ok, _ := glob.Match(pattern, t.Path())
+				return ok
+			}
+
+		case "tag", "context", "project":
+			var filters []collections.Filter[Task]
+			for _, item := range splitList(term) {
+				filters = append(filters, markerFilters[term.key](item.value))
+			}
+			filter = collections.Or(filters...)
+
+		case "is":
+			var filters []collections.Filter[Task]
+			for _, item := range splitList(term) {
+				switch strings.ToLower(item.value) {
+				case "ready":
+					filters = append(filters, FilterByReady())
+				case "available":
+					filters = append(filters, FilterByAvailable(relativeTo))
+				case "leaf":
+					filters = append(filters, FilterByLeaf())
+				case "toplevel":
+					filters = append(filters, FilterByTopLevel())
+				default:
+					return Query{}, errorAt(item.start, "unknown value '%s', expected ready, available, leaf or toplevel", item.value)
+				}
+			}
+			filter = collections.And(filters...)
+
+		case "sort":
+			for _, item := range splitList(term) {
+				name, descending := strings.CutPrefix(strings.ToLower(item.value), "-")
+				sorter, ok := querySorters[name]
+				if !ok {
+					return Query{}, errorAt(item.start, "unknown sort '%s', expected priority, due, scheduled or status", item.value)
+				}
+				if descending {
+					q.Sorters = append(q.Sorters, sorter.reverse())
+					continue
+				}
+				q.Sorters = append(q.Sorters, sorter.sorter)
+			}
+			continue
+
+		case "limit":
+			limit, err := strconv.Atoi(term.value)
+			if err != nil || limit < 1 {
+				return Query{}, errorAt(term.valueStart, "invalid limit '%s', expected a positive number", term.value)
+			}
+			q.Limit = limit
+			continue
+
+		default:
+			return Query{}, errorAt(term.start, "unknown key '%s'", term.key)
+		}
+
+		if term.negate {
+			filter = collections.Not(filter)
+		}
+		q.Filters = append(q.Filters, filter)
+	}
+	return q, nil
+}
+
+var queryKeys = []string{"status", "category", "priority", "due", "scheduled", "start", "completed", "path", "tag", "context", "project", "is", "sort", "limit"}
+
+// The same short keys as tasks
+var queryAliases = map[string]string{"d": "due", "s": "scheduled", "p": "priority", "defer": "start"}
+
+var comparableKeys = []string{"priority", "due", "scheduled", "start", "completed"}
+
+var queryOperator = parse.Any(parse.String("<="), parse.String(">="), parse.String("<"), parse.String(">"), parse.String("="), parse.String(":"))
+
+// Split the query into terms. A word followed by an operator is only a key if it's one we know, otherwise
+// (e.g. a time like 10:30) the whole term is text to look for in the name unless it looks like a misspelt key.
+func lexQuery(query string) ([]queryTerm, error) {
+	var terms []queryTerm
+	in := parse.NewInput(query)
+	for {
+		parse.ZeroOrMore(parse.RuneWhere(unicode.IsSpace)).Parse(in)
+		if _, ok := in.Peek(1); !ok {
+			return terms, nil
+		}
+
+		term := queryTerm{start: in.Index()}
+		if _, ok, _ := parse.Rune('-').Parse(in); ok {
+			term.negate = true
+		}
+		afterNegation := in.Index()
+		key, _, _ := parse.StringFrom(parse.ZeroOrMore(parse.RuneWhere(unicode.IsLetter))).Parse(in)
+		operator, ok, _ := queryOperator.Parse(in)
+		if alias, found := queryAliases[strings.ToLower(key)]; found {
+			key = alias
+		}
+		switch {
+		case ok && slices.Contains(queryKeys, strings.ToLower(key)):
+			term.key, term.operator = strings.ToLower(key), operator
+		case ok && key != "" && operator == ":":
+			return nil, &QueryError{Column: utf8.RuneCountInString(query[:term.start]) + 1, Message: fmt.Sprintf("unknown key '%s'", key)}
+		default:
+			in.Seek(afterNegation)
+		}
+
+		term.valueStart = in.Index()
+		value, quoted, err := queryValue(query, in)
+		if err != nil {
+			return nil, err
+		}
+		if quoted {
+			term.valueStart++
+		}
+		term.value = value
+		if term.key == "" && term.value == "" {
+			// A lone dash
+			return nil, &QueryError{Column: utf8.RuneCountInString(query[:term.start]) + 1, Message: "expected a term after '-'"}
+		}
+		terms = append(terms, term)
+	}
+}
+
+// Read a value up to the next whitespace, or between double quotes
+func queryValue(query string, in *parse.Input) (value string, quoted bool, err error) {
+	start := in.Index()
+	if _, ok, _ := parse.String(`"`).Parse(in); ok {
+		value, ok, _ := parse.StringUntil(parse.String(`"`)).Parse(in)
+		if !ok {
+			return "", false, &QueryError{Column: utf8.RuneCountInString(query[:start]) + 1, Message: "unterminated quote"}
+		}
+		parse.String(`"`).Parse(in)
+		return value, true, nil
+	}
+	value, _, _ = parse.StringUntil(parse.Any(parse.RuneWhere(unicode.IsSpace), parse.EOF[string]())).Parse(in)
+	return value, false, nil
+}
+
+type queryItem struct {
+	value string
+	start int
+}
+
+// The comma separated values of a term along with where each one starts
+func splitList(term queryTerm) []queryItem {
+	var items []queryItem
+	start, offset := term.valueStart, 0
+	for _, value := range strings.Split(term.value, ",") {
+		if trimmed := strings.TrimSpace(value); trimmed != "" {
+			items = append(items, queryItem{value: trimmed, start: start + offset + strings.Index(value, trimmed)})
+		}
+		offset += len(value) + 1
+	}
+	return items
+}
+
+// Statuses are looked up by name, or by the rune between the brackets
+func statusByName(statuses *Statuses, name string) (StatusDefinition, bool) {
+	for _, definition := range statuses.All() {
+		if strings.EqualFold(definition.Name, name) {
+			return definition, true
+		}
+	}
+	if utf8.RuneCountInString(name) == 1 {
+		r, _ := utf8.DecodeRuneInString(name)
+		return statuses.Lookup(r)
+	}
+	return StatusDefinition{}, false
+}
+
+func comparePriority(operator string, priority int) collections.Filter[Task] {
+	return func(t Task) bool {
+		if t.Priority() == nil {
+			return false
+		}
+		p := *t.Priority()
+		switch operator {
+		case "<":
+			return p < priority
+		case "<=":
+			return p <= priority
+		case ">":
+			return p > priority
+		default:
+			return p >= priority
+		}
+	}
+}
+
+// The inclusive range matching the comparison, a date without a time covers the whole day
+func dateRange(operator string, date parsers.Timestamp) (after *time.Time, before *time.Time) {
+	start, end := date.Time, date.End()
+	switch operator {
+	case "<":
+		before := start.Add(-time.Nanosecond)
+		return nil, &before
+	case "<=":
+		return nil, &end
+	case ">":
+		after := end.Add(time.Nanosecond)
+		return &after, nil
+	case ">=":
+		return &start, nil
+	default:
+		return &start, &end
+	}
+}
+
+var dateFields = map[string]func(Task) *parsers.Timestamp{
+	"due":       func(t Task) *parsers.Timestamp { return t.due },
+	"scheduled": func(t Task) *parsers.Timestamp { return t.scheduled },
+	"start":     func(t Task) *parsers.Timestamp { return t.start },
+	"completed": func(t Task) *parsers.Timestamp { return t.completed },
+}
+
+var markerFilters = map[string]func(...string) collections.Filter[Task]{
+	"tag":     FilterByTag,
+	"context": FilterByContext,
+	"project": FilterByProject,
+}
+
+type querySorter struct {
+	sorter collections.Sorter[Task]
+	// Whether the task has nothing to sort by, these tasks are sorted last whichever direction the sort is in
+	missing func(Task) bool
+}
+
+var querySorters = map[string]querySorter{
+	"priority":  {sorter: SortByPriority(), missing: func(t Task) bool { return t.Priority() == nil }},
+	"due":       {sorter: SortByDueDate(), missing: func(t Task) bool { return t.due == nil }},
+	"scheduled": {sorter: SortByScheduledDate(), missing: func(t Task) bool { return t.scheduled == nil }},
+	"status":    {sorter: SortByStatus(AgendaOrder()...)},
+}
+
+// Reverse the order of the tasks that have something to sort by, keeping the rest last
+func (s querySorter) reverse() collections.Sorter[Task] {
+	return func(a, b Task) int {
+		if s.missing != nil && (s.missing(a) || s.missing(b)) {
+			return s.sorter(a, b)
+		}
+		return s.sorter(b, a)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks_test
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/notedownorg/notedown/pkg/fileserver/reader"
+	"github.com/notedownorg/notedown/pkg/providers/tasks"
+	"github.com/stretchr/testify/assert"
+)
+
+func queryEvents() []reader.Event {
+	return []reader.Event{
+		{
+			Op:  reader.Load,
+			Key: "projects/house/paint.md",
+			Document: reader.Document{
+				Contents: []byte(`- [ ] Buy paint due:2024-01-03 p:2 #errands
+- [/] Paint the hall due:2024-01-10 p:1 #house
+- [x] Pick colours due:2024-01-01 #house
+`),
+				Checksum: "version",
+			},
+		},
+		{
+			Op:  reader.Load,
+			Key: "work.md",
+			Document: reader.Document{
+				Contents: []byte(`- [ ] Write report due:2024-01-02T14:00 p:1 #work
+- [w] Call bob about the invoice #work
+- [ ] Plan offsite start:2024-02-01 p:3 #work
+`),
+				Checksum: "version",
+			},
+		},
+		{Op: reader.SubscriberLoadComplete},
+	}
+}
+
+func TestQuery(t *testing.T) {
+	c, _ := buildClient(queryEvents())
+	now := *date(2024, 1, 1, 9*time.Hour)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{query: "", want: []string{"Buy paint", "Call bob about the invoice #work", "Paint the hall", "Pick colours", "Plan offsite", "Write report"}},
+		{query: "status:todo,doing sort:priority", want: []string{"Paint the hall", "Write report", "Buy paint", "Plan offsite"}},
+		{query: "status:x", want: []string{"Pick colours"}},
+		{query: "category:open -status:waiting sort:due", want: []string{"Write report", "Buy paint", "Plan offsite"}},
+		{query: "due<=+2d sort:due", want: []string{"Pick colours", "Write report", "Buy paint"}},
+		{query: "due<2024-01-03 sort:-due", want: []string{"Write report", "Pick colours"}},
+		{query: "category:open -status:waiting sort:-due", want: []string{"Buy paint", "Write report", "Plan offsite"}},
+		{query: "tag:work sort:-priority", want: []string{"Plan offsite", "Write report", "Call bob about the invoice #work"}},
+		{query: "due>2024-01-02", want: []string{"Buy paint", "Paint the hall"}},
+		{query: "due:2024-01-02", want: []string{"Write report"}},
+		{query: `due>="next mon"`, want: []string{"Paint the hall"}},
+		{query: "priority<=2 sort:-priority", want: []string{"Buy paint", "Paint the hall", "Write report"}},
+		{query: "p:3", want: []string{"Plan offsite"}},
+		{query: "path:projects/**", want: []string{"Buy paint", "Paint the hall", "Pick colours"}},
+		{query: "path:*.md", want: []string{"Call bob about the invoice #work", "Plan offsite", "Write report"}},
+		{query: "path:**/paint.md", want: []string{"Buy paint", "Paint the hall", "Pick colours"}},
+		{query: "tag:house,errands -status:done", want: []string{"Buy paint", "Paint the hall"}},
+		{query: "tag:work is:ready is:available", want: []string{"Write report"}},
+		{query: "paint", want: []string{"Buy paint", "Paint the hall"}},
+		{query: `"call bob"`, want: []string{"Call bob about the invoice #work"}},
+		{query: "status:todo,doing sort:priority,-due limit:2", want: []string{"Paint the hall", "Write report"}},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			query, err := tasks.ParseQuery(test.query, now)
+			if !assert.NoError(t, err) {
+				return
+			}
+			var got []string
+			for _, task := range c.ListTasks(tasks.FetchAllTasks(), query.Options()...) {
+				got = append(got, task.Name())
+			}
+			if !strings.Contains(test.query, "sort:") {
+				assert.ElementsMatch(t, test.want, got)
+				return
+			}
+			assert.Equal(t, test.want, got)
+		})
+	}
+}
+
+func TestQuery_Errors(t *testing.T) {
+	tests := []struct {
+		query   string
+		column  int
+		message string
+	}{
+		{query: "status:todo,dong", column: 13, message: "unknown status 'dong'"},
+		{query: "tag:work stauts:todo", column: 10, message: "unknown key 'stauts'"},
+		{query: "due<=someday", column: 6, message: "invalid date 'someday'"},
+		{query: "due:", column: 5, message: "expected a value after 'due:'"},
+		{query: "tag>work", column: 1, message: "'tag' can't be compared with '>', use 'tag:'"},
+		{query: "priority:high", column: 10, message: "invalid priority 'high', expected a number"},
+		{query: "priority<1,2", column: 10, message: "can't compare priority with more than one value"},
+		{query: "sort:priority,size", column: 15, message: "unknown sort 'size', expected priority, due, scheduled or status"},
+		{query: "-limit:2", column: 1, message: "'limit' can't be negated"},
+		{query: "limit:0", column: 7, message: "invalid limit '0', expected a positive number"},
+		{query: `due<="next fri`, column: 6, message: "unterminated quote"},
+		{query: "path:projects/[a", column: 6, message: "invalid path 'projects/[a': syntax error in pattern"},
+		{query: "is:done", column: 4, message: "unknown value 'done', expected ready, available, leaf or toplevel"},
+		{query: "café status:nope", column: 13, message: "unknown status 'nope'"},
+	}
+	for _, test := range tests {
+		t.Run(test.query, func(t *testing.T) {
+			_, err := tasks.ParseQuery(test.query, *date(2024, 1, 1, 0))
+			var queryErr *tasks.QueryError
+			if assert.True(t, errors.As(err, &queryErr), "expected a query error, got %v", err) {
+				assert.Equal(t, test.column, queryErr.Column)
+				assert.Equal(t, test.message, queryErr.Message)
+			}
+		})
+	}
+}
+
+func TestClient_Query(t *testing.T) {
+	c, _ := buildClient(queryEvents())
+
+	res, err := c.Query("tag:work sort:priority limit:1")
+	assert.NoError(t, err)
+	if assert.Len(t, res, 1) {
+		assert.Equal(t, "Write report", res[0].Name())
+	}
+
+	_, err = c.Query("status:nope")
+	assert.EqualError(t, err, "invalid query at column 8: unknown status 'nope'")
+}
@@ -0,0 +1,316 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/notedownorg/notedown/pkg/parsers"
+	"github.com/teambition/rrule-go"
+)
+
+// Why the recurrence can't be used, e.g. the rule is invalid or never occurs (every:31st feb). Tasks with such a
+// recurrence are still parsed and written back out unchanged but they won't repeat.
+func (e Every) Err() error {
+	return e.err
+}
+
+// The recurrence with its occurrences counted from the given date (e.g. a task's due date) rather than the date it
+// was parsed relative to. Only the date is used so every 2 weeks from a Monday is always on a Monday.
+func (e Every) StartingOn(start time.Time) Every {
+	if e.rrule == nil {
+		return e
+	}
+	opts := e.rrule.OrigOptions
+	opts.Dtstart = day(start)
+	rr, err := rrule.NewRRule(opts)
+	if err != nil {
+		e.err = err
+		return e
+	}
+	e.rrule = rr
+	return e
+}
+
+// The first occurrence after the given time (exclusive), false if there isn't one e.g. the recurrence has passed its
+// until date or used up its count.
+func (e Every) Next(after time.Time) (time.Time, bool) {
+	if e.rrule == nil {
+		return time.Time{}, false
+	}
+	next := e.rrule.After(after, false)
+	return next, !next.IsZero()
+}
+
+// All occurrences between start and end (inclusive)
+func (e Every) Between(start time.Time, end time.Time) []time.Time {
+	if e.rrule == nil {
+		return nil
+	}
+	return e.rrule.Between(start, end, true)
+}
+
+// The first occurrence after the given day (exclusive) with the recurrence starting on start, or the zero time if
+// there isn't one.
+func (e Every) next(start time.Time, after time.Time) time.Time {
+	next, _ := e.StartingOn(start).Next(day(after))
+	return next
+}
+
+var (
+	countText    = regexp.MustCompile(`for \d+ times?`)
+	rawCountText = regexp.MustCompile(`COUNT=\d+`)
+)
+
+// The recurrence with one fewer occurrence left, rewriting the count in the text so the file stays in sync.
+func (e Every) decrement() Every {
+	if e.rrule == nil {
+		return e
+	}
+	opts := e.rrule.OrigOptions
+	if opts.Count <= 1 {
+		return e
+	}
+	opts.Count--
+	rr, err := rrule.NewRRule(opts)
+	if err != nil {
+		return e
+	}
+	res := e
+	res.rrule = rr
+	if strings.HasPrefix(e.text, "RRULE:") || strings.HasPrefix(e.text, "FREQ=") {
+		res.text = rawCountText.ReplaceAllString(e.text, "COUNT="+strconv.Itoa(opts.Count))
+		return res
+	}
+	unit := "times"
+	if opts.Count == 1 {
+		unit = "time"
+	}
+	res.text = countText.ReplaceAllString(e.text, fmt.Sprintf("for %d %s", opts.Count, unit))
+	return res
+}
+
+// The open task to leave in place of a completed recurring task with its dates moved to the next occurrence.
+//
+// By default the recurrence is on a fixed schedule from the due date so the next occurrence is the first one on that
+// schedule after the task was completed (or after the current due date if it was completed early). Recurrences from
+// completion (every!:) restart from the day the task was completed instead. The scheduled and start dates are moved by
+// the same number of days as the due date, if the task has no due date the scheduled date is used instead.
+//
+// Returns false if the recurrence has run out of occurrences (it has passed its until date or used up its count), in
+// which case the task should just be completed.
+func reopen(t Task) (Task, bool) {
+	open := NewTaskFromTask(t)
+	open.status = Todo
+	open.completed = nil
+	open.uncommittedRepeat = false
+	open.spent, open.started = nil, nil
+	if t.every != nil && t.every.err != nil {
+		return t, false
+	}
+
+	base := t.due
+	if base == nil {
+		base = t.scheduled
+	}
+	if base == nil || t.every == nil {
+		return open, true
+	}
+
+	completed := base.Time
+	if t.completed != nil {
+		completed = t.completed.Time
+	}
+
+	var next time.Time
+	if t.every.fromCompletion {
+		next = t.every.next(completed, completed)
+	} else {
+		after := day(base.Time)
+		if day(completed).After(after) {
+			after = day(completed)
+		}
+		next = t.every.next(base.Time, after)
+	}
+	if next.IsZero() {
+		return t, false
+	}
+
+	days := int(next.Sub(day(base.Time)).Hours() / 24)
+	open.due = shift(t.due, days)
+	open.scheduled = shift(t.scheduled, days)
+	open.start = shift(t.start, days)
+	every := t.every.decrement()
+	open.every = &every
+	return open, true
+}
+
+func shift(ts *parsers.Timestamp, days int) *parsers.Timestamp {
+	if ts == nil {
+		return nil
+	}
+	return &parsers.Timestamp{Time: ts.AddDate(0, 0, days), Layout: ts.Layout}
+}
+
+// The date (at midnight UTC) of the time in its own location
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// A human readable description of the recurrence e.g. "every 2 weeks" or "on the last Friday of every month".
+// Rules that can't be described (and invalid rules) are described by their text.
+func (e Every) Describe() string {
+	if e.rrule == nil {
+		return e.text
+	}
+	opts := e.rrule.OrigOptions
+	if len(opts.Byhour) > 0 || len(opts.Byminute) > 0 || len(opts.Bysecond) > 0 || len(opts.Byyearday) > 0 ||
+		len(opts.Byweekno) > 0 || len(opts.Byeaster) > 0 {
+		return e.text
+	}
+	for _, wd := range opts.Byweekday {
+		if wd.N() != 0 {
+			return e.text
+		}
+	}
+
+	var b strings.Builder
+	switch {
+	case len(opts.Bysetpos) > 0 && len(opts.Byweekday) > 0 && opts.Freq == rrule.MONTHLY:
+		fmt.Fprintf(&b, "on the %s %s of %s", join(ordinals(opts.Bysetpos), "and"), describeWeekdays(opts.Byweekday, "or"), everyN(opts.Interval, "month"))
+	case len(opts.Bysetpos) > 0:
+		return e.text
+	case len(opts.Bymonthday) > 0 && (opts.Freq == rrule.YEARLY || opts.Freq == rrule.MONTHLY) && len(opts.Byweekday) == 0:
+		days := ordinals(opts.Bymonthday)
+		if len(days) == 1 && days[0] == "last" {
+			days[0] = "last day"
+		}
+		of := everyN(opts.Interval, "month")
+		if len(opts.Bymonth) > 0 {
+			months := make([]string, len(opts.Bymonth))
+			for i, m := range opts.Bymonth {
+				months[i] = time.Month(m).String()
+			}
+			of = join(months, "and")
+		}
+		fmt.Fprintf(&b, "on the %s of %s", join(days, "and"), of)
+	case len(opts.Bymonthday) > 0 || len(opts.Bymonth) > 0:
+		return e.text
+	case len(opts.Byweekday) > 0 && opts.Freq == rrule.WEEKLY:
+		if opts.Interval > 1 {
+			fmt.Fprintf(&b, "%s on %s", everyN(opts.Interval, "week"), describeWeekdays(opts.Byweekday, "and"))
+		} else {
+			fmt.Fprintf(&b, "every %s", describeWeekdays(opts.Byweekday, "and"))
+		}
+	case len(opts.Byweekday) > 0:
+		return e.text
+	default:
+		b.WriteString(everyN(opts.Interval, frequencyUnits[opts.Freq]))
+	}
+
+	if e.fromCompletion {
+		b.WriteString(fromCompletion)
+	}
+	if !opts.Until.IsZero() {
+		until := parsers.NewTimestamp(opts.Until)
+		if until.Time.Equal(parsers.Timestamp{Time: day(opts.Until), Layout: parsers.DateLayout}.End()) {
+			until = parsers.NewTimestamp(day(opts.Until))
+		}
+		fmt.Fprintf(&b, " until %s", until)
+	}
+	switch {
+	case opts.Count == 1:
+		b.WriteString(", once")
+	case opts.Count > 1:
+		fmt.Fprintf(&b, ", %d times", opts.Count)
+	}
+	return b.String()
+}
+
+var frequencyUnits = map[rrule.Frequency]string{
+	rrule.YEARLY:   "year",
+	rrule.MONTHLY:  "month",
+	rrule.WEEKLY:   "week",
+	rrule.DAILY:    "day",
+	rrule.HOURLY:   "hour",
+	rrule.MINUTELY: "minute",
+	rrule.SECONDLY: "second",
+}
+
+// e.g. every month or every 3 months
+func everyN(interval int, unit string) string {
+	if interval <= 1 {
+		return "every " + unit
+	}
+	return fmt.Sprintf("every %d %ss", interval, unit)
+}
+
+func describeWeekdays(days []rrule.Weekday, conjunction string) string {
+	weekdays := map[int]bool{}
+	for _, d := range days {
+		weekdays[d.Day()] = true
+	}
+	if len(weekdays) == 5 && !weekdays[rrule.SA.Day()] && !weekdays[rrule.SU.Day()] {
+		return "weekday"
+	}
+	names := make([]string, len(days))
+	for i, d := range days {
+		// rrule counts from Monday, time from Sunday
+		names[i] = time.Weekday((d.Day() + 1) % 7).String()
+	}
+	return join(names, conjunction)
+}
+
+// 1st, 2nd, last, 2nd last etc.
+func ordinals(ns []int) []string {
+	res := make([]string, len(ns))
+	for i, n := range ns {
+		switch {
+		case n == -1:
+			res[i] = "last"
+		case n < 0:
+			res[i] = nth(-n) + " last"
+		default:
+			res[i] = nth(n)
+		}
+	}
+	return res
+}
+
+func nth(n int) string {
+	suffix := "th"
+	switch {
+	case n%100 >= 11 && n%100 <= 13:
+	case n%10 == 1:
+		suffix = "st"
+	case n%10 == 2:
+		suffix = "nd"
+	case n%10 == 3:
+		suffix = "rd"
+	}
+	return strconv.Itoa(n) + suffix
+}
+
+// a, b and c
+func join(items []string, conjunction string) string {
+	if len(items) <= 1 {
+		return strings.Join(items, "")
+	}
+	return strings.Join(items[:len(items)-1], ", ") + " " + conjunction + " " + items[len(items)-1]
+}
@@ -0,0 +1,253 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/a-h/parse"
+	. "github.com/notedownorg/notedown/pkg/parsers"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReopen(t *testing.T) {
+	tests := []struct {
+		name      string
+		every     string
+		options   []TaskOption
+		completed time.Time
+		want      string
+		finished  bool
+	}{
+		{
+			name:      "Due and scheduled move by the same offset",
+			every:     "week",
+			options:   []TaskOption{WithDue(date(2024, 1, 1)), WithScheduled(date(2023, 12, 30))},
+			completed: date(2024, 1, 3),
+			want:      "- [ ] Task due:2024-01-08 scheduled:2024-01-06 every:week",
+		},
+		{
+			name:      "Completed late stays on the schedule",
+			every:     "2 weeks",
+			options:   []TaskOption{WithDue(date(2024, 1, 1))},
+			completed: date(2024, 1, 20),
+			want:      "- [ ] Task due:2024-01-29 every:2 weeks",
+		},
+		{
+			name:      "From completion",
+			every:     "every!:2 weeks",
+			options:   []TaskOption{WithDue(date(2024, 1, 1))},
+			completed: date(2024, 1, 5),
+			want:      "- [ ] Task due:2024-01-19 every!:2 weeks",
+		},
+		{
+			name:      "From completion when completed early",
+			every:     "day from completion",
+			options:   []TaskOption{WithDue(date(2024, 1, 10)), WithScheduled(date(2024, 1, 8))},
+			completed: date(2024, 1, 5),
+			want:      "- [ ] Task due:2024-01-06 scheduled:2024-01-04 every:day from completion",
+		},
+		{
+			name:      "Completed early moves on from the due date",
+			every:     "day",
+			options:   []TaskOption{withDue(Timestamp{Time: time.Date(2024, 1, 10, 9, 0, 0, 0, time.UTC), Layout: "2006-01-02 15:04"})},
+			completed: date(2024, 1, 5),
+			want:      "- [ ] Task due:2024-01-11 09:00 every:day",
+		},
+		{
+			name:      "Day of the week",
+			every:     "mon",
+			options:   []TaskOption{WithDue(date(2024, 1, 1))},
+			completed: time.Date(2024, 1, 2, 18, 30, 0, 0, time.UTC),
+			want:      "- [ ] Task due:2024-01-08 every:mon",
+		},
+		{
+			name:      "Day of the month",
+			every:     "15th",
+			options:   []TaskOption{WithScheduled(date(2024, 1, 15))},
+			completed: date(2024, 1, 20),
+			want:      "- [ ] Task scheduled:2024-02-15 every:15th",
+		},
+		{
+			name:      "Ordinal weekday",
+			every:     "2nd tue",
+			options:   []TaskOption{WithDue(date(2024, 1, 9))},
+			completed: date(2024, 1, 9),
+			want:      "- [ ] Task due:2024-02-13 every:2nd tue",
+		},
+		{
+			name:      "Last weekday of the month",
+			every:     "last friday",
+			options:   []TaskOption{WithDue(date(2024, 1, 26))},
+			completed: date(2024, 1, 27),
+			want:      "- [ ] Task due:2024-02-23 every:last friday",
+		},
+		{
+			name:      "Last day of the month",
+			every:     "last day of month",
+			options:   []TaskOption{WithDue(date(2024, 1, 31))},
+			completed: date(2024, 1, 31),
+			want:      "- [ ] Task due:2024-02-29 every:last day of month",
+		},
+		{
+			name:      "Before until",
+			every:     "week until 2024-01-10",
+			options:   []TaskOption{WithDue(date(2024, 1, 1))},
+			completed: date(2024, 1, 3),
+			want:      "- [ ] Task due:2024-01-08 every:week until 2024-01-10",
+		},
+		{
+			name:      "After until",
+			every:     "week until 2024-01-10",
+			options:   []TaskOption{WithDue(date(2024, 1, 8))},
+			completed: date(2024, 1, 8),
+			finished:  true,
+		},
+		{
+			name:      "Count goes down",
+			every:     "week for 3 times",
+			options:   []TaskOption{WithDue(date(2024, 1, 1))},
+			completed: date(2024, 1, 1),
+			want:      "- [ ] Task due:2024-01-08 every:week for 2 times",
+		},
+		{
+			name:      "Count goes down to one",
+			every:     "week for 2 times from completion",
+			options:   []TaskOption{WithDue(date(2024, 1, 1))},
+			completed: date(2024, 1, 3),
+			want:      "- [ ] Task due:2024-01-10 every:week for 1 time from completion",
+		},
+		{
+			name:      "Count used up",
+			every:     "week for 1 time",
+			options:   []TaskOption{WithDue(date(2024, 1, 1))},
+			completed: date(2024, 1, 1),
+			finished:  true,
+		},
+		{
+			name:      "Raw rule count goes down",
+			every:     "RRULE:FREQ=WEEKLY;COUNT=3",
+			options:   []TaskOption{WithDue(date(2024, 1, 1))},
+			completed: date(2024, 1, 1),
+			want:      "- [ ] Task due:2024-01-08 every:RRULE:FREQ=WEEKLY;COUNT=2",
+		},
+		{
+			name:      "Time spent isn't carried over",
+			every:     "day",
+			options:   []TaskOption{WithDue(date(2024, 1, 1)), WithEstimate(time.Hour), WithSpent(45 * time.Minute)},
+			completed: date(2024, 1, 1),
+			want:      "- [ ] Task due:2024-01-02 every:day estimate:1h",
+		},
+		{
+			name:      "No dates",
+			every:     "day",
+			completed: date(2024, 1, 20),
+			want:      "- [ ] Task every:day",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			every, err := NewEvery(test.every)
+			assert.NoError(t, err)
+			task := NewTask(NewIdentifier("path", "version", 1), "Task", Doing, append(test.options, WithEvery(every))...)
+			completed := NewTaskFromTask(task, WithCompleted(test.completed), WithStatus(Done))
+			open, ok := reopen(completed)
+			assert.Equal(t, !test.finished, ok)
+			if ok {
+				assert.Equal(t, test.want, open.String())
+			}
+		})
+	}
+}
+
+func TestEvery_Occurrences(t *testing.T) {
+	every, err := NewEvery("2nd tue")
+	assert.NoError(t, err)
+	every = every.StartingOn(date(2024, 1, 1))
+
+	next, ok := every.Next(date(2024, 1, 9))
+	assert.True(t, ok)
+	assert.Equal(t, date(2024, 2, 13), next)
+
+	assert.Equal(t, []time.Time{date(2024, 1, 9), date(2024, 2, 13), date(2024, 3, 12)}, every.Between(date(2024, 1, 1), date(2024, 3, 31)))
+
+	limited, err := NewEvery("week for 2 times")
+	assert.NoError(t, err)
+	limited = limited.StartingOn(date(2024, 1, 1))
+	assert.Equal(t, []time.Time{date(2024, 1, 1), date(2024, 1, 8)}, limited.Between(date(2024, 1, 1), date(2024, 12, 31)))
+	_, ok = limited.Next(date(2024, 1, 8))
+	assert.False(t, ok)
+}
+
+func TestEvery_Describe(t *testing.T) {
+	tests := []struct {
+		every string
+		want  string
+	}{
+		{every: "day", want: "every day"},
+		{every: "2 weeks", want: "every 2 weeks"},
+		{every: "weekday", want: "every weekday"},
+		{every: "mon wed fri", want: "every Monday, Wednesday and Friday"},
+		{every: "RRULE:FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,TH", want: "every 2 weeks on Tuesday and Thursday"},
+		{every: "2nd tuesday", want: "on the 2nd Tuesday of every month"},
+		{every: "last fri", want: "on the last Friday of every month"},
+		{every: "RRULE:FREQ=MONTHLY;BYDAY=MO,TU,WE,TH,FR;BYSETPOS=-1", want: "on the last weekday of every month"},
+		{every: "last day of month", want: "on the last day of every month"},
+		{every: "1st 15th", want: "on the 1st and 15th of every month"},
+		{every: "jan mar sept", want: "on the 1st of January, March and September"},
+		{every: "month for 6 times", want: "every month, 6 times"},
+		{every: "weekday until 2025-06-30", want: "every weekday until 2025-06-30"},
+		{every: "every!:3 days", want: "every 3 days from completion"},
+		{every: "RRULE:FREQ=DAILY;BYHOUR=9", want: "RRULE:FREQ=DAILY;BYHOUR=9"},
+	}
+	for _, test := range tests {
+		t.Run(test.every, func(t *testing.T) {
+			every, err := NewEvery(test.every)
+			assert.NoError(t, err)
+			assert.Equal(t, test.want, every.Describe())
+		})
+	}
+}
+
+func TestEvery_Err(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{input: "- [ ] Task every:week", err: ""},
+		{input: "- [ ] Task every:week until 2000-01-01", err: ""},
+		{input: "- [ ] Task every:31st feb", err: "recurrence never occurs"},
+		{input: "- [ ] Task every:32nd", err: "invalid recurrence: bymonthday must be between 1 and 31 or -1 and -31"},
+		{input: "- [ ] Task every:RRULE:FREQ=SOMETIMES", err: "invalid recurrence: undefined frequency: SOMETIMES"},
+	}
+	for _, test := range tests {
+		t.Run(test.input, func(t *testing.T) {
+			task, found, err := ParseTask("path", "version", relativeTo).Parse(parse.NewInput(test.input))
+			assert.NoError(t, err)
+			assert.True(t, found)
+			assert.Equal(t, test.input, task.String())
+			if test.err == "" {
+				assert.NoError(t, task.Every().Err())
+				return
+			}
+			assert.EqualError(t, task.Every().Err(), test.err)
+
+			// Invalid recurrences don't repeat
+			_, ok := reopen(NewTaskFromTask(task, WithStatus(Done)))
+			assert.False(t, ok)
+		})
+	}
+}
@@ -15,6 +15,10 @@
 package tasks
 
 import (
+	"cmp"
+	"slices"
+
+	"github.com/notedownorg/notedown/pkg/parsers"
 	"github.com/notedownorg/notedown/pkg/providers/pkg/collections"
 )
 
@@ -43,47 +47,58 @@ func SortByPriority() collections.Sorter[Task] {
 	}
 }
 
-func AgendaOrder() (Status, Status, Status, Status, Status) {
-	return Doing, Todo, Blocked, Done, Abandoned
+// Tasks without a due date are sorted last. Dates without a time cover the whole day so are sorted after
+// times on the same day.
+func SortByDueDate() collections.Sorter[Task] {
+	return func(a, b Task) int {
+		return compareTimestamps(a.due, b.due)
+	}
+}
+
+// Tasks without a scheduled date are sorted last, mixed precision is handled in the same way as SortByDueDate.
+func SortByScheduledDate() collections.Sorter[Task] {
+	return func(a, b Task) int {
+		return compareTimestamps(a.scheduled, b.scheduled)
+	}
+}
+
+func compareTimestamps(a, b *parsers.Timestamp) int {
+	if a == nil && b == nil {
+		return 0
+	}
+	if a == nil {
+		return 1
+	}
+	if b == nil {
+		return -1
+	}
+	return a.Compare(*b)
+}
+
+func AgendaOrder() []Status {
+	return []Status{Doing, Todo, Waiting, Blocked, Done, Abandoned}
 }
 
-func KanbanOrder() (Status, Status, Status, Status, Status) {
-	return Todo, Blocked, Doing, Done, Abandoned
+func KanbanOrder() []Status {
+	return []Status{Todo, Blocked, Waiting, Doing, Done, Abandoned}
 }
 
-func SortByStatus(first, second, third, fourth, fifth Status) collections.Sorter[Task] {
+// Statuses not in the order are left where they are relative to each other but sorted after those that are.
+// Custom statuses can be slotted in by adding them to the order e.g. SortByStatus(append(KanbanOrder(), ">")...)
+func SortByStatus(order ...Status) collections.Sorter[Task] {
 	return func(a, b Task) int {
 		if a.Status() == b.Status() {
 			return 0
 		}
-		switch a.Status() {
-		case first:
-			if b.Status() == first {
-				return 0
-			}
-			return -1
-		case second:
-			if b.Status() == first {
-				return 1
-			}
-			return -1
-		case third:
-			if b.Status() == first || b.Status() == second {
-				return 1
-			}
-			return -1
-		case fourth:
-			if b.Status() == first || b.Status() == second || b.Status() == third {
-				return 1
-			}
-			return -1
-		case fifth:
-			if b.Status() == first || b.Status() == second || b.Status() == third || b.Status() == fourth {
-				return 1
-			}
-			return -1
-		default:
+		i, j := slices.Index(order, a.Status()), slices.Index(order, b.Status())
+		switch {
+		case i < 0 && j < 0:
 			return 0
+		case i < 0:
+			return 1
+		case j < 0:
+			return -1
 		}
+		return cmp.Compare(i, j)
 	}
 }
@@ -17,6 +17,7 @@ package tasks_test
 import (
 	"testing"
 
+	"github.com/notedownorg/notedown/pkg/fileserver/reader"
 	"github.com/notedownorg/notedown/pkg/providers/pkg/collections"
 	"github.com/notedownorg/notedown/pkg/providers/tasks"
 	"github.com/stretchr/testify/assert"
@@ -33,7 +34,7 @@ func TestSorters(t *testing.T) {
 	}{
 		{
 			name:   "Sort by status -> kanban order (then alphabetical)",
-			sorter: tasks.SortByStatus(tasks.KanbanOrder()),
+			sorter: tasks.SortByStatus(tasks.KanbanOrder()...),
 			wantTasks: []tasks.Task{
 				eventTasks["one.md"][1],
 				eventTasks["one.md"][2],
@@ -48,7 +49,7 @@ func TestSorters(t *testing.T) {
 		},
 		{
 			name:   "Sort by status -> agenda order (then alphabetical)",
-			sorter: tasks.SortByStatus(tasks.AgendaOrder()),
+			sorter: tasks.SortByStatus(tasks.AgendaOrder()...),
 			wantTasks: []tasks.Task{
 				eventTasks["one.md"][0],
 				eventTasks["zero.md"][2],
@@ -96,7 +97,7 @@ func TestSortersMultiple(t *testing.T) {
 		{
 			name: "Sort by status -> agenda order, then by priority",
 			sorters: []collections.Sorter[tasks.Task]{
-				tasks.SortByStatus(tasks.AgendaOrder()),
+				tasks.SortByStatus(tasks.AgendaOrder()...),
 				tasks.SortByPriority(),
 			},
 			wantTasks: []tasks.Task{
@@ -118,3 +119,38 @@ func TestSortersMultiple(t *testing.T) {
 		})
 	}
 }
+
+func TestSorters_MixedPrecision(t *testing.T) {
+	c, _ := buildClient(mixedPrecisionEvents())
+
+	var got []string
+	for _, task := range c.ListTasks(tasks.FetchAllTasks(), tasks.WithSorters(tasks.SortByDueDate())) {
+		got = append(got, task.Name())
+	}
+	assert.Equal(t, []string{"Morning", "Afternoon", "All day", "Next day", "Whenever"}, got)
+}
+
+func TestSorters_Waiting(t *testing.T) {
+	c, _ := buildClient([]reader.Event{
+		{
+			Op:  reader.Load,
+			Key: "statuses.md",
+			Document: reader.Document{
+				Contents: []byte("- [x] Done\n- [w] Waiting\n- [b] Blocked\n- [ ] Todo\n- [/] Doing\n- [a] Abandoned\n"),
+				Checksum: "version",
+			},
+		},
+		{Op: reader.SubscriberLoadComplete},
+	})
+
+	names := func(sorter collections.Sorter[tasks.Task]) []string {
+		var res []string
+		for _, task := range c.ListTasks(tasks.FetchAllTasks(), tasks.WithSorters(sorter)) {
+			res = append(res, task.Name())
+		}
+		return res
+	}
+
+	assert.Equal(t, []string{"Doing", "Todo", "Waiting", "Blocked", "Done", "Abandoned"}, names(tasks.SortByStatus(tasks.AgendaOrder()...)))
+	assert.Equal(t, []string{"Todo", "Blocked", "Waiting", "Doing", "Done", "Abandoned"}, names(tasks.SortByStatus(tasks.KanbanOrder()...)))
+}
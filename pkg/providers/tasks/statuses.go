@@ -0,0 +1,151 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"fmt"
+	"slices"
+	"unicode"
+	"unicode/utf8"
+
+	"sigs.k8s.io/yaml"
+)
+
+// Categories group statuses by how far along a task is, they decide how the rest of notedown treats the status.
+type Category string
+
+const (
+	// Not started yet e.g. todo, blocked or waiting
+	Open Category = "open"
+	// Being worked on e.g. doing
+	Active Category = "active"
+	// Finished with e.g. done or abandoned. Closing a task completes it: it's given a completed date, recurring tasks
+	// roll forward, tasks that depend on it are unblocked and it counts towards its parent's progress.
+	Closed Category = "closed"
+)
+
+type StatusDefinition struct {
+	// The rune written between the brackets, other cases of the rune are read as the same status e.g. [X] is done
+	Status   Status   `json:"rune"`
+	Name     string   `json:"name"`
+	Category Category `json:"category"`
+}
+
+// The statuses tasks can have, tasks with a rune between the brackets that isn't in the registry aren't tasks.
+type Statuses struct {
+	definitions []StatusDefinition
+}
+
+var defaultStatuses = &Statuses{definitions: []StatusDefinition{
+	{Status: Todo, Name: "todo", Category: Open},
+	{Status: Blocked, Name: "blocked", Category: Open},
+	{Status: Waiting, Name: "waiting", Category: Open},
+	{Status: Doing, Name: "doing", Category: Active},
+	{Status: Done, Name: "done", Category: Closed},
+	{Status: Abandoned, Name: "abandoned", Category: Closed},
+}}
+
+// The built in statuses: todo, blocked, waiting, doing, done and abandoned
+func DefaultStatuses() *Statuses {
+	return defaultStatuses
+}
+
+// Create a registry from the defaults and the given statuses. A status with the same rune as a default replaces it.
+func NewStatuses(definitions ...StatusDefinition) (*Statuses, error) {
+	res := &Statuses{definitions: slices.Clone(defaultStatuses.definitions)}
+	seen := make(map[Status]bool)
+	for _, d := range definitions {
+		if utf8.RuneCountInString(string(d.Status)) != 1 || d.Status == "[" || d.Status == "]" || d.Status == "\n" {
+			return nil, fmt.Errorf("invalid status '%s': must be a single rune other than a bracket or newline", d.Status)
+		}
+		if d.Name == "" {
+			return nil, fmt.Errorf("invalid status '%s': must have a name", d.Status)
+		}
+		if d.Category != Open && d.Category != Active && d.Category != Closed {
+			return nil, fmt.Errorf("invalid status '%s': unknown category '%s'", d.Status, d.Category)
+		}
+		if seen[d.Status] {
+			return nil, fmt.Errorf("invalid status '%s': declared more than once", d.Status)
+		}
+		seen[d.Status] = true
+
+		if i := slices.IndexFunc(res.definitions, func(e StatusDefinition) bool { return e.Status == d.Status }); i >= 0 {
+			res.definitions[i] = d
+		} else {
+			res.definitions = append(res.definitions, d)
+		}
+	}
+	return res, nil
+}
+
+// Load statuses from a YAML (or JSON) list of rune, name and category, e.g.
+//
+//   - rune: ">"
+//     name: forwarded
+//     category: closed
+//
+// The statuses are added to the defaults.
+func LoadStatuses(data []byte) (*Statuses, error) {
+	var definitions []StatusDefinition
+	if err := yaml.UnmarshalStrict(data, &definitions); err != nil {
+		return nil, fmt.Errorf("failed to load statuses: %w", err)
+	}
+	return NewStatuses(definitions...)
+}
+
+// All of the statuses in the order they were registered
+func (s *Statuses) All() []StatusDefinition {
+	return slices.Clone(s.definitions)
+}
+
+// The status for the rune, an exact match is preferred before trying other cases
+func (s *Statuses) Lookup(r rune) (StatusDefinition, bool) {
+	if d, ok := s.Get(Status(r)); ok {
+		return d, true
+	}
+	for f := unicode.SimpleFold(r); f != r; f = unicode.SimpleFold(f) {
+		if d, ok := s.Get(Status(f)); ok {
+			return d, true
+		}
+	}
+	return StatusDefinition{}, false
+}
+
+func (s *Statuses) Get(status Status) (StatusDefinition, bool) {
+	i := slices.IndexFunc(s.definitions, func(d StatusDefinition) bool { return d.Status == status })
+	if i < 0 {
+		return StatusDefinition{}, false
+	}
+	return s.definitions[i], true
+}
+
+// Statuses that aren't registered are treated as open
+func (s *Statuses) Category(status Status) Category {
+	if d, ok := s.Get(status); ok {
+		return d.Category
+	}
+	return Open
+}
+
+// The statuses in any of the given categories, in the order they were registered
+func (s *Statuses) InCategory(categories ...Category) []Status {
+	var res []Status
+	for _, d := range s.definitions {
+		if slices.Contains(categories, d.Category) {
+			res = append(res, d.Status)
+		}
+	}
+	return res
+}
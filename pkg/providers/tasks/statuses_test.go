@@ -0,0 +1,185 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/notedownorg/notedown/pkg/fileserver/reader"
+	"github.com/notedownorg/notedown/pkg/fileserver/writer"
+	"github.com/notedownorg/notedown/pkg/providers/pkg/collections"
+	"github.com/notedownorg/notedown/pkg/providers/pkg/test"
+	"github.com/notedownorg/notedown/pkg/providers/tasks"
+	"github.com/stretchr/testify/assert"
+)
+
+const customStatuses = `
+- rune: ">"
+  name: forwarded
+  category: closed
+- rune: "?"
+  name: question
+  category: open
+- rune: "-"
+  name: cancelled
+  category: closed
+- rune: "b"
+  name: backlog
+  category: open
+`
+
+func TestLoadStatuses(t *testing.T) {
+	statuses, err := tasks.LoadStatuses([]byte(customStatuses))
+	assert.NoError(t, err)
+
+	forwarded, ok := statuses.Lookup('>')
+	assert.True(t, ok)
+	assert.Equal(t, tasks.StatusDefinition{Status: ">", Name: "forwarded", Category: tasks.Closed}, forwarded)
+
+	// Defaults are kept unless they're replaced and other cases of a rune are the same status
+	done, ok := statuses.Lookup('X')
+	assert.True(t, ok)
+	assert.Equal(t, tasks.Done, done.Status)
+	backlog, ok := statuses.Lookup('B')
+	assert.True(t, ok)
+	assert.Equal(t, "backlog", backlog.Name)
+	_, ok = statuses.Lookup('!')
+	assert.False(t, ok)
+
+	assert.Equal(t, []tasks.Status{tasks.Done, tasks.Abandoned, ">", "-"}, statuses.InCategory(tasks.Closed))
+}
+
+func TestLoadStatuses_Invalid(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		err    string
+	}{
+		{name: "Not a list", config: `rune: ">"`, err: "failed to load statuses"},
+		{name: "Unknown key", config: `[{rune: ">", name: forwarded, category: closed, colour: red}]`, err: "unknown field"},
+		{name: "Multiple runes", config: `[{rune: ">>", name: forwarded, category: closed}]`, err: "must be a single rune"},
+		{name: "Bracket", config: `[{rune: "]", name: bracket, category: closed}]`, err: "must be a single rune"},
+		{name: "No name", config: `[{rune: ">", category: closed}]`, err: "must have a name"},
+		{name: "Unknown category", config: `[{rune: ">", name: forwarded, category: finished}]`, err: "unknown category 'finished'"},
+		{name: "Duplicate", config: `[{rune: ">", name: forwarded, category: closed}, {rune: ">", name: other, category: open}]`, err: "declared more than once"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := tasks.LoadStatuses([]byte(test.config))
+			assert.ErrorContains(t, err, test.err)
+		})
+	}
+}
+
+func TestCustomStatuses(t *testing.T) {
+	events := []reader.Event{
+		{
+			Op:  reader.Load,
+			Key: "custom.md",
+			Document: reader.Document{
+				Contents: []byte(`- [ ] Parent
+  - [>] Forwarded id:fwd
+  - [?] Question
+- [ ] Follow up after:fwd
+- [-] Cancelled
+`),
+				Checksum: "version",
+			},
+		},
+		{Op: reader.SubscriberLoadComplete},
+	}
+	feed := make(chan reader.Event)
+	go func() {
+		for _, event := range events {
+			feed <- event
+		}
+	}()
+	statuses, err := tasks.LoadStatuses([]byte(customStatuses))
+	assert.NoError(t, err)
+	c := tasks.NewClient(&test.MockDocumentContentUpdater{}, feed, tasks.WithInitialLoadWaiter(100*time.Millisecond), tasks.WithStatuses(statuses))
+
+	names := func(filter collections.Filter[tasks.Task]) []string {
+		var res []string
+		for _, task := range c.ListTasks(tasks.FetchAllTasks(), tasks.WithFilters(filter)) {
+			res = append(res, task.Name())
+		}
+		return res
+	}
+
+	assert.ElementsMatch(t, []string{"Forwarded", "Cancelled"}, names(tasks.FilterByCategory(tasks.Closed)))
+	assert.ElementsMatch(t, []string{"Parent", "Question", "Follow up"}, names(tasks.FilterByReady()))
+
+	parent := c.ListTasks(tasks.FetchAllTasks(), tasks.WithFilters(func(t tasks.Task) bool { return t.Name() == "Parent" }))[0]
+	done, total := parent.Progress()
+	assert.Equal(t, 1, done)
+	assert.Equal(t, 2, total)
+
+	forwarded, ok := c.TaskByID("fwd")
+	assert.True(t, ok)
+	assert.Equal(t, "  - [>] Forwarded id:fwd", forwarded.String())
+
+	// Closing a task with a custom status completes it
+	question := c.ListTasks(tasks.FetchAllTasks(), tasks.WithFilters(tasks.FilterByStatus("?")))[0]
+	assert.Nil(t, question.Completed())
+	assert.NotNil(t, tasks.NewTaskFromTask(question, tasks.WithStatus("-")).Completed())
+	assert.Nil(t, tasks.NewTaskFromTask(question, tasks.WithStatus(tasks.Doing)).Completed())
+}
+
+func TestCustomStatuses_NotRegistered(t *testing.T) {
+	c, _ := buildClient([]reader.Event{
+		{
+			Op:  reader.Load,
+			Key: "custom.md",
+			Document: reader.Document{
+				Contents: []byte("- [ ] Parent\n  - [?] Question\n- [>] Forwarded\n"),
+				Checksum: "version",
+			},
+		},
+		{Op: reader.SubscriberLoadComplete},
+	})
+
+	// Without the statuses registered these aren't tasks so the question is a note on its parent
+	all := c.ListTasks(tasks.FetchAllTasks())
+	assert.Len(t, all, 1)
+	assert.Equal(t, []string{"  - [?] Question"}, all[0].Notes())
+}
+
+func TestCustomStatuses_Create(t *testing.T) {
+	feed := make(chan reader.Event)
+	go func() { feed <- reader.Event{Op: reader.SubscriberLoadComplete} }()
+	statuses, err := tasks.LoadStatuses([]byte(customStatuses))
+	assert.NoError(t, err)
+
+	var written []string
+	updater := &test.MockDocumentContentUpdater{Validators: []test.ContentUpdateValidator{
+		func(doc writer.Document, mutations ...writer.LineMutation) error {
+			for _, mutation := range mutations {
+				var err error
+				if written, err = mutation("", written); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	}}
+	c := tasks.NewClient(updater, feed, tasks.WithInitialLoadWaiter(100*time.Millisecond), tasks.WithStatuses(statuses))
+
+	// Created tasks use the client's statuses so moving one to a custom closed status completes it
+	assert.NoError(t, c.Create("path", writer.AT_END, "Pass on to Sam", tasks.Todo, tasks.WithStatus(">")))
+	if assert.Len(t, written, 1) {
+		assert.Regexp(t, `^- \[>\] Pass on to Sam completed:\d{4}-\d{2}-\d{2}T\d{2}:\d{2}$`, written[0])
+	}
+}
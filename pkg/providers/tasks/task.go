@@ -16,10 +16,12 @@ package tasks
 
 import (
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
 	"github.com/a-h/parse"
+	"github.com/notedownorg/notedown/pkg/parsers"
 	"github.com/teambition/rrule-go"
 )
 
@@ -55,17 +57,60 @@ const (
 	Doing     Status = "/"
 	Done      Status = "x"
 	Abandoned Status = "a"
+	Waiting   Status = "w"
 )
 
 type Task struct {
 	identifier Identifier
 	name       string
 	status     Status
-	due        *time.Time
-	scheduled  *time.Time
-	completed  *time.Time
+	due        *parsers.Timestamp
+	scheduled  *parsers.Timestamp
+	start      *parsers.Timestamp
+	completed  *parsers.Timestamp
 	priority   *int
 	every      *Every
+	id         string
+	after      []string
+	estimate   *time.Duration
+	spent      *time.Duration
+
+	// When the task was last moved to an active status, only set when time tracking is used
+	started *parsers.Timestamp
+
+	// Resolved against the rest of the workspace when the task is fetched. BlockedBy holds the dependencies that
+	// aren't done yet and cycle is set if the task (indirectly) depends on itself.
+	blockedBy []string
+	cycle     bool
+
+	// Tags, contexts and projects written after the fields, any before the fields are part of the name
+	trailing []string
+
+	// The whitespace before the list item, this is preserved when the task is written back out
+	indent string
+
+	// Lines indented under the task that aren't subtasks e.g. paragraphs, links or bullet points. They directly
+	// follow the task so occupy the lines immediately after it.
+	notes []string
+
+	// Subtasks are tasks indented underneath another task in the same list, they are linked when a document is parsed.
+	// Parent and children are line numbers in the same document with 0 meaning no parent. End is the last line
+	// of the task's subtree or 0 if it has no subtasks.
+	depth        int
+	parent       int
+	children     []int
+	end          int
+	subtasks     int
+	subtasksDone int
+
+	// All of the task's subtasks (including nested subtasks) in the order they appear, as they were when it was parsed
+	descendants []Task
+
+	// The statuses the task was parsed with, nil for the defaults
+	statuses *Statuses
+
+	// The line the task was parsed from, this allows us to locate the task if the document changes before it is written
+	source string
 
 	// This is used to track if the task has been mutated to done and has an every set
 	// but not yet written back to the file. This is so that we can handle the repeat.
@@ -75,11 +120,19 @@ type Task struct {
 type Every struct {
 	rrule *rrule.RRule
 	text  string // maintain the original text for every so we can write it back out
+
+	// Whether the next occurrence is calculated from when the task was completed rather than its due date
+	// and whether this was written as every!: (rather than as a suffix) so we can write it back out the same way
+	fromCompletion bool
+	bang           bool
+
+	// Why the recurrence can't be used, the text is still kept so it's written back out unchanged
+	err error
 }
 
 func NewEvery(text string) (Every, error) {
-	// Handle e:<text> vs every:<text> vs <text>
-	if !strings.HasPrefix(text, "e:") && !strings.HasPrefix(text, "every:") {
+	// Handle e:<text> vs every:<text> vs <text> (and their every!: equivalents)
+	if !strings.HasPrefix(text, "e:") && !strings.HasPrefix(text, "every:") && !strings.HasPrefix(text, "e!:") && !strings.HasPrefix(text, "every!:") {
 		text = "e:" + text
 	}
 
@@ -93,10 +146,21 @@ func NewEvery(text string) (Every, error) {
 	return e, nil
 }
 
+// The recurrence as it was written (without the every: key). A recurrence written with every!: has " from completion"
+// added so passing the result back to NewEvery keeps when the next occurrence is calculated from.
 func (e Every) String() string {
+	if e.bang && !strings.HasSuffix(e.text, fromCompletion) {
+		return e.text + fromCompletion
+	}
 	return e.text
 }
 
+// Whether the next occurrence is calculated from when the task was completed (every!: or a "from completion" suffix)
+// rather than on a fixed schedule from its due date
+func (e Every) FromCompletion() bool {
+	return e.fromCompletion
+}
+
 type TaskOption func(*Task)
 
 // Used to create new tasks. For mutating tasks, use NewTaskFromTask.
@@ -119,11 +183,31 @@ func NewTaskFromTask(t Task, options ...TaskOption) Task {
 		identifier:        t.identifier,
 		name:              t.name,
 		status:            t.status,
+		statuses:          t.statuses,
 		due:               t.due,
 		scheduled:         t.scheduled,
+		start:             t.start,
 		completed:         t.completed,
 		priority:          t.priority,
 		every:             t.every,
+		id:                t.id,
+		after:             slices.Clone(t.after),
+		estimate:          t.estimate,
+		spent:             t.spent,
+		started:           t.started,
+		blockedBy:         slices.Clone(t.blockedBy),
+		cycle:             t.cycle,
+		trailing:          slices.Clone(t.trailing),
+		indent:            t.indent,
+		notes:             slices.Clone(t.notes),
+		depth:             t.depth,
+		parent:            t.parent,
+		children:          slices.Clone(t.children),
+		end:               t.end,
+		subtasks:          t.subtasks,
+		subtasksDone:      t.subtasksDone,
+		descendants:       slices.Clone(t.descendants),
+		source:            t.source,
 		uncommittedRepeat: t.uncommittedRepeat,
 	}
 	for _, option := range options {
@@ -141,12 +225,12 @@ func WithName(name string) TaskOption {
 func WithStatus(status Status) TaskOption {
 	return func(t *Task) {
 
-		// If the task is being marked as done and it wasn't done before...
-		if status == Done && t.status != Done {
+		// If the task is being closed (e.g. marked as done) and it wasn't closed before...
+		if t.registry().Category(status) == Closed && !t.Closed() {
 
-			// If there is no completed time, set it to now
+			// If there is no completed time, set it to now (to the minute, in local time)
 			if t.completed == nil {
-				WithCompleted(time.Now())(t)
+				withCompleted(localMinute(time.Now()))(t)
 			}
 
 			// If the task has a repeat, mark the task so we know we need to handle it when persisting
@@ -158,19 +242,48 @@ func WithStatus(status Status) TaskOption {
 	}
 }
 
+// The time to the minute in its own location without a zone offset e.g. 2024-01-01T09:30
+func localMinute(now time.Time) parsers.Timestamp {
+	local := time.Date(now.Year(), now.Month(), now.Day(), now.Hour(), now.Minute(), 0, 0, time.UTC)
+	return parsers.Timestamp{Time: local, Layout: parsers.DateLayout + "T15:04"}
+}
+
+// Dates at midnight UTC are written without a time, see parsers.NewTimestamp for how other times are written
 func WithDue(due time.Time) TaskOption {
+	return withDue(parsers.NewTimestamp(due))
+}
+
+func withDue(due parsers.Timestamp) TaskOption {
 	return func(t *Task) {
 		t.due = &due
 	}
 }
 
 func WithScheduled(scheduled time.Time) TaskOption {
+	return withScheduled(parsers.NewTimestamp(scheduled))
+}
+
+func withScheduled(scheduled parsers.Timestamp) TaskOption {
 	return func(t *Task) {
 		t.scheduled = &scheduled
 	}
 }
 
+func WithStart(start time.Time) TaskOption {
+	return withStart(parsers.NewTimestamp(start))
+}
+
+func withStart(start parsers.Timestamp) TaskOption {
+	return func(t *Task) {
+		t.start = &start
+	}
+}
+
 func WithCompleted(completed time.Time) TaskOption {
+	return withCompleted(parsers.NewTimestamp(completed))
+}
+
+func withCompleted(completed parsers.Timestamp) TaskOption {
 	return func(t *Task) {
 		t.completed = &completed
 	}
@@ -182,12 +295,82 @@ func WithPriority(priority int) TaskOption {
 	}
 }
 
+func WithEstimate(estimate time.Duration) TaskOption {
+	return func(t *Task) {
+		t.estimate = &estimate
+	}
+}
+
+func WithSpent(spent time.Duration) TaskOption {
+	return func(t *Task) {
+		t.spent = &spent
+	}
+}
+
+func WithStarted(started time.Time) TaskOption {
+	return withStarted(parsers.NewTimestamp(started))
+}
+
+func withStarted(started parsers.Timestamp) TaskOption {
+	return func(t *Task) {
+		t.started = &started
+	}
+}
+
 func WithEvery(every Every) TaskOption {
 	return func(t *Task) {
 		t.every = &every
 	}
 }
 
+// Give the task an ID so other tasks can depend on it, IDs should be unique across the workspace
+func WithID(id string) TaskOption {
+	return func(t *Task) {
+		t.id = id
+	}
+}
+
+// Set the IDs of the tasks that must be done before this task can be started
+func WithAfter(ids ...string) TaskOption {
+	return func(t *Task) {
+		t.after = ids
+	}
+}
+
+// Set the whitespace before the list item, use this to create a subtask by indenting it further than its parent
+func WithIndent(indent string) TaskOption {
+	return func(t *Task) {
+		t.indent = indent
+	}
+}
+
+// Set the lines written underneath the task, these should be indented further than the task
+func WithNotes(notes ...string) TaskOption {
+	return func(t *Task) {
+		t.notes = notes
+	}
+}
+
+func withTrailing(markers []string) TaskOption {
+	return func(t *Task) {
+		t.trailing = markers
+	}
+}
+
+func withStatuses(statuses *Statuses) TaskOption {
+	return func(t *Task) {
+		if statuses != defaultStatuses {
+			t.statuses = statuses
+		}
+	}
+}
+
+func withSource(source string) TaskOption {
+	return func(t *Task) {
+		t.source = source
+	}
+}
+
 func (t Task) Identifier() Identifier {
 	return t.identifier
 }
@@ -216,7 +399,7 @@ func (t Task) Due() *time.Time {
 	if t.due == nil {
 		return nil
 	}
-	res := *t.due
+	res := t.due.Time
 	return &res
 }
 
@@ -224,7 +407,16 @@ func (t Task) Scheduled() *time.Time {
 	if t.scheduled == nil {
 		return nil
 	}
-	res := *t.scheduled
+	res := t.scheduled.Time
+	return &res
+}
+
+// The date the task is deferred until, it isn't available to be worked on before then
+func (t Task) Start() *time.Time {
+	if t.start == nil {
+		return nil
+	}
+	res := t.start.Time
 	return &res
 }
 
@@ -232,7 +424,34 @@ func (t Task) Completed() *time.Time {
 	if t.completed == nil {
 		return nil
 	}
-	res := *t.completed
+	res := t.completed.Time
+	return &res
+}
+
+// How long the task is expected to take
+func (t Task) Estimate() *time.Duration {
+	if t.estimate == nil {
+		return nil
+	}
+	res := *t.estimate
+	return &res
+}
+
+// How long has been spent on the task so far, not including the time since it was started if it's still active
+func (t Task) Spent() *time.Duration {
+	if t.spent == nil {
+		return nil
+	}
+	res := *t.spent
+	return &res
+}
+
+// When the task was last started, see WithTimeTracking
+func (t Task) Started() *time.Time {
+	if t.started == nil {
+		return nil
+	}
+	res := t.started.Time
 	return &res
 }
 
@@ -252,27 +471,149 @@ func (t Task) Every() *Every {
 	return &res
 }
 
+func (t Task) ID() string {
+	return t.id
+}
+
+// The IDs of the tasks that must be done before this task can be started
+func (t Task) After() []string {
+	return slices.Clone(t.after)
+}
+
+// The IDs of the dependencies that aren't done yet (including any that don't exist). Only populated for tasks
+// returned by the client.
+func (t Task) BlockedBy() []string {
+	return slices.Clone(t.blockedBy)
+}
+
+// Whether the task depends on itself through its dependencies so can never be started
+func (t Task) InCycle() bool {
+	return t.cycle
+}
+
+// The task's status taking its dependencies into account, tasks that aren't closed but are waiting on other tasks
+// are Blocked
+func (t Task) EffectiveStatus() Status {
+	if !t.Closed() && (len(t.blockedBy) > 0 || t.cycle) {
+		return Blocked
+	}
+	return t.status
+}
+
+func (t Task) Category() Category {
+	return t.registry().Category(t.status)
+}
+
+// Whether the task is finished with e.g. done or abandoned
+func (t Task) Closed() bool {
+	return t.Category() == Closed
+}
+
+func (t Task) registry() *Statuses {
+	if t.statuses == nil {
+		return defaultStatuses
+	}
+	return t.statuses
+}
+
+// Tags are written as #tag, they are returned without the # in the order they appear
+func (t Task) Tags() []string {
+	return parseMarkers(tagPrefix, t.markerText())
+}
+
+// Contexts are written as @context, they are returned without the @ in the order they appear
+func (t Task) Contexts() []string {
+	return parseMarkers(contextPrefix, t.markerText())
+}
+
+// Projects are written as +project, they are returned without the + in the order they appear
+func (t Task) Projects() []string {
+	return parseMarkers(projectPrefix, t.markerText())
+}
+
+func (t Task) markerText() string {
+	return strings.Join(append([]string{t.name}, t.trailing...), " ")
+}
+
+func (t Task) Indent() string {
+	return t.indent
+}
+
+// The lines indented under the task that aren't subtasks, as they are written in the document
+func (t Task) Notes() []string {
+	return slices.Clone(t.notes)
+}
+
+// The first and last line of the task's notes or 0, 0 if it doesn't have any
+func (t Task) NotesRange() (start int, end int) {
+	if len(t.notes) == 0 {
+		return 0, 0
+	}
+	return t.Line() + 1, t.Line() + len(t.notes)
+}
+
+// Depth is the number of ancestors the task has, top level tasks have a depth of 0
+func (t Task) Depth() int {
+	return t.depth
+}
+
+// The task this task is a subtask of or nil if it is a top level task
+func (t Task) Parent() *Identifier {
+	if t.parent == 0 {
+		return nil
+	}
+	res := NewIdentifier(t.Path(), t.Version(), t.parent)
+	return &res
+}
+
+// The task's direct subtasks in the order they appear in the document
+func (t Task) Children() []Identifier {
+	res := make([]Identifier, 0, len(t.children))
+	for _, line := range t.children {
+		res = append(res, NewIdentifier(t.Path(), t.Version(), line))
+	}
+	return res
+}
+
+func (t Task) IsLeaf() bool {
+	return len(t.children) == 0
+}
+
+func (t Task) IsTopLevel() bool {
+	return t.parent == 0
+}
+
+// Progress counts all of the task's subtasks (including nested subtasks) and how many of them are done
+func (t Task) Progress() (done int, total int) {
+	return t.subtasksDone, t.subtasks
+}
+
+// The last line of the task including its notes and subtasks
+func (t Task) last() int {
+	return max(t.end, t.Line()+len(t.notes))
+}
+
 func (t Task) String() string {
-	return fmt.Sprintf("- [%v] %v", t.status, t.Body())
+	return fmt.Sprintf("%s- [%v] %v", t.indent, t.status, t.Body())
 }
 
+// The task without its indentation and status. Tasks parsed from a document are written back over the line they were
+// read from, changing only what has changed since.
 func (t Task) Body() string {
+	if t.source != "" {
+		if body, ok := t.preservedBody(); ok {
+			return body
+		}
+	}
 	var b strings.Builder
 	b.WriteString(t.name)
-	if t.due != nil {
-		b.WriteString(fmt.Sprintf(" due:%v", t.due.Format("2006-01-02")))
-	}
-	if t.scheduled != nil {
-		b.WriteString(fmt.Sprintf(" scheduled:%v", t.scheduled.Format("2006-01-02")))
-	}
-	if t.priority != nil {
-		b.WriteString(fmt.Sprintf(" priority:%v", *t.priority))
-	}
-	if t.every != nil {
-		b.WriteString(fmt.Sprintf(" every:%s", t.every))
+	for _, format := range fieldFormats {
+		if key, value, ok := format.format(t); ok {
+			b.WriteString(" " + key + value)
+		}
 	}
-	if t.completed != nil {
-		b.WriteString(fmt.Sprintf(" completed:%v", t.completed.Format("2006-01-02")))
+	for _, marker := range t.trailing {
+		b.WriteString(" " + marker)
 	}
 	return b.String()
 }
@@ -0,0 +1,60 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import "time"
+
+// Start the clock on tasks that have become active and stop it on tasks that no longer are, adding the time since
+// they were started to the time spent on them
+func track(t Task, now time.Time) Task {
+	active := t.Category() == Active
+	switch {
+	case active && t.started == nil:
+		started := localMinute(now)
+		t.started = &started
+	case !active && t.started != nil:
+		elapsed := max(localMinute(now).Sub(t.started.Time), 0)
+		if t.spent != nil {
+			elapsed += *t.spent
+		}
+		t.spent = &elapsed
+		t.started = nil
+	}
+	return t
+}
+
+// The sum of the estimates of the tasks. Subtasks are counted separately from their parents so filter the tasks
+// (e.g. with FilterByLeaf) first if both have estimates.
+func TotalEstimate(tasks []Task) time.Duration {
+	var res time.Duration
+	for _, t := range tasks {
+		if t.estimate != nil {
+			res += *t.estimate
+		}
+	}
+	return res
+}
+
+// The sum of the time spent on the tasks, subtasks are counted separately from their parents in the same way as
+// TotalEstimate
+func TotalSpent(tasks []Task) time.Duration {
+	var res time.Duration
+	for _, t := range tasks {
+		if t.spent != nil {
+			res += *t.spent
+		}
+	}
+	return res
+}
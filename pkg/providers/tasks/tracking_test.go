@@ -0,0 +1,75 @@
+// Copyright 2024 Notedown Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tasks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrack(t *testing.T) {
+	now := time.Date(2024, 1, 1, 10, 15, 30, 0, time.UTC)
+	nine := time.Date(2024, 1, 1, 9, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		task Task
+		want string
+	}{
+		{
+			name: "Starting",
+			task: NewTask(NewIdentifier("path", "version", 1), "Task", Doing),
+			want: "- [/] Task started:2024-01-01T10:15",
+		},
+		{
+			name: "Already started",
+			task: NewTask(NewIdentifier("path", "version", 1), "Task", Doing, WithStarted(nine)),
+			want: "- [/] Task started:2024-01-01T09:00",
+		},
+		{
+			name: "Stopping",
+			task: NewTask(NewIdentifier("path", "version", 1), "Task", Done, WithStarted(nine), WithCompleted(now)),
+			want: "- [x] Task spent:1h15m completed:2024-01-01T10:15:30",
+		},
+		{
+			name: "Adds to the time already spent",
+			task: NewTask(NewIdentifier("path", "version", 1), "Task", Todo, WithEstimate(2*time.Hour), WithSpent(30*time.Minute), WithStarted(nine)),
+			want: "- [ ] Task estimate:2h spent:1h45m",
+		},
+		{
+			name: "Never started",
+			task: NewTask(NewIdentifier("path", "version", 1), "Task", Done),
+			want: "- [x] Task",
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			assert.Equal(t, test.want, track(test.task, now).String())
+		})
+	}
+}
+
+func TestTotals(t *testing.T) {
+	tasks := []Task{
+		NewTask(NewIdentifier("path", "version", 1), "One", Todo, WithEstimate(time.Hour), WithSpent(45*time.Minute)),
+		NewTask(NewIdentifier("path", "version", 2), "Two", Todo, WithEstimate(30*time.Minute)),
+		NewTask(NewIdentifier("path", "version", 3), "Three", Todo),
+	}
+	assert.Equal(t, 90*time.Minute, TotalEstimate(tasks))
+	assert.Equal(t, 45*time.Minute, TotalSpent(tasks))
+	assert.Zero(t, TotalEstimate(nil))
+}
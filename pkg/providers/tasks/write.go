@@ -15,52 +15,294 @@
 package tasks
 
 import (
+	"cmp"
 	"fmt"
 	"log/slog"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/notedownorg/notedown/pkg/fileserver/writer"
 )
 
+type writeConfig struct {
+	writer  DocumentUpdater
+	wait    time.Duration
+	cascade bool
+	track   bool
+}
+
+type writeOptions func(*writeConfig)
+
+// Record the changes in the dry run rather than writing them to disk
+func WithDryRun(dryRun *writer.DryRun) writeOptions {
+	return func(config *writeConfig) {
+		config.writer = dryRun
+	}
+}
+
+// Wait until the change is visible to the client (i.e. the version that was written has been handled) before returning,
+// erroring if this takes longer than the timeout. Has no effect on dry runs. If the writer isn't a
+// VersionedDocumentUpdater the version isn't known so any event for the document handled after the write is waited for.
+// Pair with writer.WithReader to avoid waiting on the file watcher.
+func WithWait(timeout time.Duration) writeOptions {
+	return func(config *writeConfig) {
+		config.wait = timeout
+	}
+}
+
+// When a task is completed also complete any of its subtasks that are still open, all in a single write
+func WithCascade() writeOptions {
+	return func(config *writeConfig) {
+		config.cascade = true
+	}
+}
+
+// Record when a task is moved to an active status (e.g. doing) in a started: field, when it moves to any other status
+// the time since then is added to its spent: time
+func WithTimeTracking() writeOptions {
+	return func(config *writeConfig) {
+		config.track = true
+	}
+}
+
+func (c *Client) writeConfig(opts []writeOptions) writeConfig {
+	config := writeConfig{writer: c.writer}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	if _, ok := config.writer.(*writer.DryRun); ok {
+		config.wait = 0
+	}
+	return config
+}
+
+// Write the document and if required wait for the new version to be handled before returning
+func (c *Client) update(config writeConfig, doc writer.Document, mutations ...writer.LineMutation) error {
+	if config.wait == 0 {
+		return config.writer.UpdateContent(doc, mutations...)
+	}
+
+	// An event for the document may already be in flight so where possible wait for the exact version that was written
+	var written func() bool
+	if versioned, ok := config.writer.(VersionedDocumentUpdater); ok {
+		version, err := versioned.UpdateContentVersion(doc, mutations...)
+		if err != nil {
+			return err
+		}
+		written = func() bool { return c.Version(doc.Path) == version }
+	} else {
+		before := c.Handled(doc.Path)
+		if err := config.writer.UpdateContent(doc, mutations...); err != nil {
+			return err
+		}
+		written = func() bool { return c.Handled(doc.Path) > before }
+	}
+
+	visible := c.WaitFor(config.wait, written)
+	if !visible {
+		return fmt.Errorf("timed out waiting for %s to be updated", doc.Path)
+	}
+	return nil
+}
+
 func (c *Client) Create(path string, line int, name string, status Status, options ...TaskOption) error {
-	task := NewTask(NewIdentifier(path, "", line), name, status, options...)
+	task := NewTask(NewIdentifier(path, "", line), name, status, append([]TaskOption{withStatuses(c.statuses)}, options...)...)
+	if c.generateIDs && task.id == "" {
+		task.id = c.generateID()
+	}
 	slog.Debug("creating task", "identifier", task.Identifier().String(), "task", task.String())
 
 	mutation := writer.AddLine(task.Line(), task)
-	if err := c.writer.UpdateContent(writer.Document{Path: path}, mutation); err != nil {
+	if len(task.notes) > 0 {
+		for _, note := range task.notes {
+			if strings.TrimSpace(note) != "" && indentWidth(note) <= indentWidth(task.indent) {
+				return fmt.Errorf("failed to add task: %v: note '%s' must be indented further than the task", task, note)
+			}
+		}
+		mutation = writer.AddBlock(task.Line(), taskWithNotes(task))
+	}
+	doc := writer.Document{Path: path, Description: fmt.Sprintf("tasks: add '%s' to %s", task.Name(), path)}
+	if err := c.writer.UpdateContent(doc, mutation); err != nil {
 		return fmt.Errorf("failed to add task: %v: %w", task, err)
 	}
 	return nil
 }
 
-func (c *Client) Update(t Task) error {
+// The task followed by its notes, as a new task is written to the document
+type taskWithNotes Task
+
+func (t taskWithNotes) String() string {
+	return strings.Join(append([]string{Task(t).String()}, t.notes...), "\n")
+}
+
+func (c *Client) Update(t Task, opts ...writeOptions) error {
 	slog.Debug("updating task", "identifier", t.Identifier().String(), "task", t.String())
+	config := c.writeConfig(opts)
+	doc := writer.Document{Path: t.Path(), Checksum: t.Version(), Description: describeUpdate(t)}
 
+	if config.track {
+		t = track(t, time.Now())
+	}
+	edits := updateEdits(t)
+	if config.cascade && t.Closed() {
+		for _, subtask := range openSubtasks(t) {
+			options := []TaskOption{WithStatus(t.Status())}
+			if t.completed != nil {
+				options = []TaskOption{withCompleted(*t.completed), WithStatus(t.Status())}
+			}
+			edits = append(edits, updateEdits(NewTaskFromTask(subtask, options...))...)
+		}
+	}
+
+	// Apply the edits from the bottom of the document up so each one leaves the lines above it untouched
+	slices.SortStableFunc(edits, func(a, b edit) int { return cmp.Compare(b.position, a.position) })
+	mutations := make([]writer.LineMutation, 0, len(edits))
+	for _, e := range edits {
+		mutations = append(mutations, e.mutation)
+	}
+	if err := c.update(config, doc, mutations...); err != nil {
+		return fmt.Errorf("failed to update task: %v: %w", t, err)
+	}
+	return nil
+}
+
+type edit struct {
+	// Twice the line number, minus one for inserts so they sort before updates to the line they are inserted at
+	position int
+	mutation writer.LineMutation
+}
+
+func updateEdits(t Task) []edit {
 	// If this task has been flagged as completed with recurrence handle it.
 	if t.uncommittedRepeat {
-		// Task completion is handled by adding the completed task to the line below.
-		// - [ ] Task every:day
+		// Task completion is handled by adding the completed task below the task and any subtasks and moving the
+		// open task's dates forward to the next occurrence.
+		// - [ ] Task every:day due:2024-01-01
 		// after:
-		// - [ ] Task every:day
-		// - [x] Task every:day completed:2024-01-01
-		mutation := writer.AddLine(t.Line()+1, t)
-		if err := c.writer.UpdateContent(writer.Document{Path: t.Path(), Checksum: t.Version()}, mutation); err != nil {
-			return fmt.Errorf("failed to update task: %v: %w", t, err)
+		// - [ ] Task every:day due:2024-01-02
+		// - [x] Task every:day due:2024-01-01 completed:2024-01-01
+		// The open task keeps the ID so the completed copy doesn't duplicate it. The whole block is anchored so the
+		// copy can't end up in the middle of subtasks or notes that have been added since the task was read.
+		offset := t.last() - t.Line() + 1
+		completed := NewTaskFromTask(t, WithID(""))
+		open, ok := reopen(t)
+		if !ok {
+			// The recurrence has finished so there's nothing left to reopen
+			return []edit{{
+				position: 2 * t.Line(),
+				mutation: anchored(t, func(line int) writer.LineMutation { return writer.UpdateLine(line, t) }),
+			}}
+		}
+		return []edit{
+			{
+				position: 2*(t.last()+1) - 1,
+				mutation: anchoredBlock(t, func(line int) writer.LineMutation { return writer.AddLine(line+offset, completed) }),
+			},
+			{
+				position: 2 * t.Line(),
+				mutation: anchored(t, func(line int) writer.LineMutation { return writer.UpdateLine(line, open) }),
+			},
 		}
-		return nil
 	}
+	return []edit{{
+		position: 2 * t.Line(),
+		mutation: anchored(t, func(line int) writer.LineMutation { return writer.UpdateLine(line, t) }),
+	}}
+}
 
-	mutation := writer.UpdateLine(t.Line(), t)
-	if err := c.writer.UpdateContent(writer.Document{Path: t.Path(), Checksum: t.Version()}, mutation); err != nil {
-		return fmt.Errorf("failed to update task: %v: %w", t, err)
+// All of the task's subtasks (including nested subtasks) that are neither done nor abandoned. These are the subtasks as
+// they were when the task was read, rather than from the cache, so they match the (possibly stale) task's line numbers.
+func openSubtasks(t Task) []Task {
+	var res []Task
+	for _, subtask := range t.descendants {
+		if !subtask.Closed() {
+			res = append(res, subtask)
+		}
 	}
-	return nil
+	return res
 }
 
-func (c *Client) Delete(t Task) error {
+// Delete the task along with its notes and subtasks
+func (c *Client) Delete(t Task, opts ...writeOptions) error {
 	slog.Debug("deleting task", "identifier", t.Identifier().String(), "task", t.String())
-	mutation := writer.RemoveLine(t.Line())
-	if err := c.writer.UpdateContent(writer.Document{Path: t.Path(), Checksum: t.Version()}, mutation); err != nil {
+	config := c.writeConfig(opts)
+
+	// The task's notes and subtasks are removed along with it
+	size := t.last() - t.Line()
+	mutation := anchoredBlock(t, func(line int) writer.LineMutation { return writer.RemoveLines(line, line+size) })
+	doc := writer.Document{Path: t.Path(), Checksum: t.Version(), Description: fmt.Sprintf("tasks: delete '%s' from %s", t.Name(), t.Path())}
+	if err := c.update(config, doc, mutation); err != nil {
 		return fmt.Errorf("failed to remove task: %v: %w", t, err)
 	}
 	return nil
 }
+
+// Describe the update based on the status the task is being moved to
+func describeUpdate(t Task) string {
+	verb := "update"
+	switch {
+	case t.Status() == Done:
+		verb = "complete"
+	case t.Status() == Abandoned:
+		verb = "abandon"
+	case t.Closed():
+		verb = "close"
+	}
+	return fmt.Sprintf("tasks: %s '%s' in %s", verb, t.Name(), t.Path())
+}
+
+// Anchor the mutation to the line the task was parsed from. This allows the mutation to be applied even if the
+// document has been modified elsewhere since the task was read. Tasks that weren't parsed from a document (and
+// therefore have no source line) rely on the checksum alone.
+func anchored(t Task, mutation func(line int) writer.LineMutation) writer.LineMutation {
+	if t.source == "" {
+		return mutation(t.Line())
+	}
+	return writer.Anchored(t.Line(), t.source, mutation)
+}
+
+// Anchor the mutation to the task along with its notes and subtasks, for mutations that depend on the size of the
+// block. If any line of the block has changed, or lines have been added under it, since the task was read a
+// ConflictError is returned.
+func anchoredBlock(t Task, mutation func(line int) writer.LineMutation) writer.LineMutation {
+	if t.source == "" {
+		return mutation(t.Line())
+	}
+	block, ok := block(t)
+	if !ok {
+		return func(checksum string, lines []string) ([]string, error) {
+			return lines, &writer.ConflictError{Line: t.Line(), Content: t.source, Reason: "subtasks have changed since the task was read"}
+		}
+	}
+	return writer.AnchoredBlock(t.Line(), block, func(line int) writer.LineMutation {
+		return func(checksum string, lines []string) ([]string, error) {
+			// Anything indented under the task after the block (e.g. a new last note) would belong to it too
+			for _, next := range lines[min(line-1+len(block), len(lines)):] {
+				if strings.TrimSpace(next) == "" {
+					continue
+				}
+				if indentWidth(next) > indentWidth(t.indent) {
+					return lines, &writer.ConflictError{Line: t.Line(), Content: t.source, Reason: "lines have been added to the task since it was read"}
+				}
+				break
+			}
+			return mutation(line)(checksum, lines)
+		}
+	})
+}
+
+// The lines the task, its notes and its subtasks (with their notes) were read from
+func block(t Task) ([]string, bool) {
+	lines := append([]string{t.source}, t.notes...)
+	for _, subtask := range t.descendants {
+		if subtask.source == "" {
+			return nil, false
+		}
+		lines = append(append(lines, subtask.source), subtask.notes...)
+	}
+	if len(lines) != t.last()-t.Line()+1 {
+		return nil, false
+	}
+	return lines, true
+}
@@ -15,12 +15,18 @@
 package tasks_test
 
 import (
+	"crypto/sha256"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/notedownorg/notedown/pkg/fileserver/reader"
 	"github.com/notedownorg/notedown/pkg/fileserver/writer"
+	"github.com/notedownorg/notedown/pkg/providers/pkg/test"
 	"github.com/notedownorg/notedown/pkg/providers/tasks"
 	"github.com/stretchr/testify/assert"
 )
@@ -30,7 +36,7 @@ func TestWrite(t *testing.T) {
 
 		// Create
 		func(doc writer.Document, mutations ...writer.LineMutation) error {
-			assert.Equal(t, writer.Document{Path: "path"}, doc)
+			assert.Equal(t, writer.Document{Path: "path", Description: "tasks: add 'Task' to path"}, doc)
 			lines := []string{"line 1", "line 2", "line 3"}
 			for _, mutation := range mutations {
 				lines, _ = mutation("", lines)
@@ -41,7 +47,7 @@ func TestWrite(t *testing.T) {
 
 		// Update
 		func(doc writer.Document, mutations ...writer.LineMutation) error {
-			assert.Equal(t, writer.Document{Path: "path", Checksum: "version"}, doc)
+			assert.Equal(t, writer.Document{Path: "path", Checksum: "version", Description: "tasks: update 'Task' in path"}, doc)
 			lines := []string{"line 1", "line 2", "line 3"}
 			for _, mutation := range mutations {
 				lines, _ = mutation("version", lines)
@@ -52,19 +58,21 @@ func TestWrite(t *testing.T) {
 
 		// Update with recurrence completion
 		func(doc writer.Document, mutations ...writer.LineMutation) error {
-			assert.Equal(t, writer.Document{Path: "path", Checksum: "version"}, doc)
+			assert.Equal(t, writer.Document{Path: "path", Checksum: "version", Description: "tasks: complete 'Task' in path"}, doc)
 			lines := []string{"line 1", "line 2", "- [ ] Task every:day"}
 			for _, mutation := range mutations {
 				lines, _ = mutation("version", lines)
 			}
-			completed := fmt.Sprintf("- [x] Task every:day completed:%s", time.Now().Format("2006-01-02"))
-			assert.Equal(t, []string{"line 1", "line 2", "- [ ] Task every:day", completed}, lines)
+			// Completion is recorded to the minute
+			assert.Len(t, lines, 4)
+			assert.Equal(t, []string{"line 1", "line 2", "- [ ] Task every:day"}, lines[:3])
+			assert.Regexp(t, `^- \[x\] Task every:day completed:\d{4}-\d{2}-\d{2}T\d{2}:\d{2}$`, lines[3])
 			return nil
 		},
 
 		// Delete
 		func(doc writer.Document, mutations ...writer.LineMutation) error {
-			assert.Equal(t, writer.Document{Path: "path", Checksum: "version"}, doc)
+			assert.Equal(t, writer.Document{Path: "path", Checksum: "version", Description: "tasks: delete 'Task' from path"}, doc)
 			lines := []string{"line 1", "- [ ] Task", "line 3"}
 			for _, mutation := range mutations {
 				lines, _ = mutation("version", lines)
@@ -72,6 +80,15 @@ func TestWrite(t *testing.T) {
 			assert.Equal(t, []string{"line 1", "line 3"}, lines)
 			return nil
 		},
+
+		// Update a task that has moved since it was read
+		func(doc writer.Document, mutations ...writer.LineMutation) error {
+			assert.Equal(t, writer.Document{Path: "path", Checksum: "version", Description: "tasks: update 'Task' in path"}, doc)
+			var written []string
+			err := staleWriter(t, []string{"new line", "line 1", "- [ ] Task", "line 3"}, &written)(doc, mutations...)
+			assert.Equal(t, []string{"new line", "line 1", "- [/] Task", "line 3"}, written)
+			return err
+		},
 	)
 
 	assert.NoError(t, client.Create("path", writer.AT_END, "Task", tasks.Todo))
@@ -85,4 +102,411 @@ func TestWrite(t *testing.T) {
 
 	assert.NoError(t, client.Delete(tasks.NewTask(tasks.NewIdentifier("path", "version", 2), "Task", tasks.Todo)))
 
+	moved := tasks.NewTask(tasks.NewIdentifier("path", "version", 2), "Task", tasks.Todo, tasks.WithSource("- [ ] Task"))
+	assert.NoError(t, client.Update(tasks.NewTaskFromTask(moved, tasks.WithStatus(tasks.Doing))))
+
+}
+
+func TestWrite_DryRun(t *testing.T) {
+	dir := t.TempDir()
+	contents := []byte("- [ ] Task\n- [ ] Other\n")
+	if err := os.WriteFile(filepath.Join(dir, "path.md"), contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+	checksum := fmt.Sprintf("%x", sha256.Sum256(contents))
+	dryRun := writer.NewClient(dir).DryRun()
+
+	// No validators so any write reaching the client's writer would fail
+	client, _ := buildClient([]reader.Event{{Op: reader.SubscriberLoadComplete}})
+
+	task := tasks.NewTask(tasks.NewIdentifier("path.md", checksum, 1), "Task", tasks.Doing, tasks.WithSource("- [ ] Task"))
+	assert.NoError(t, client.Update(task, tasks.WithDryRun(dryRun)))
+	assert.NoError(t, client.Delete(tasks.NewTask(tasks.NewIdentifier("path.md", checksum, 2), "Other", tasks.Todo, tasks.WithSource("- [ ] Other")), tasks.WithDryRun(dryRun)))
+
+	diffs := dryRun.Diffs()
+	assert.Len(t, diffs, 1)
+	assert.Equal(t, "- [/] Task\n", string(diffs[0].After))
+	after, err := os.ReadFile(filepath.Join(dir, "path.md"))
+	assert.NoError(t, err)
+	assert.Equal(t, string(contents), string(after))
+}
+
+func TestWrite_Wait(t *testing.T) {
+	var feed chan reader.Event
+	client, feed := buildClient([]reader.Event{{Op: reader.SubscriberLoadComplete}},
+		// Visible once the event for the write has been handled
+		func(doc writer.Document, mutations ...writer.LineMutation) error {
+			go func() {
+				time.Sleep(50 * time.Millisecond)
+				feed <- reader.Event{Op: reader.Change, Key: "path", Document: reader.Document{Contents: []byte("- [/] Task\n"), Checksum: "next"}}
+			}()
+			return nil
+		},
+
+		// Never visible
+		func(doc writer.Document, mutations ...writer.LineMutation) error {
+			return nil
+		},
+	)
+
+	task := tasks.NewTask(tasks.NewIdentifier("path", "version", 1), "Task", tasks.Doing, tasks.WithSource("- [ ] Task"))
+	assert.NoError(t, client.Update(task, tasks.WithWait(time.Second)))
+	assert.Len(t, client.ListTasks(tasks.FetchAllTasks()), 1)
+
+	assert.Error(t, client.Update(task, tasks.WithWait(50*time.Millisecond)))
+}
+
+func TestWrite_WaitForVersion(t *testing.T) {
+	dir := t.TempDir()
+	contents := []byte("- [ ] Task\n")
+	if err := os.WriteFile(filepath.Join(dir, "path.md"), contents, 0644); err != nil {
+		t.Fatal(err)
+	}
+	checksum := fmt.Sprintf("%x", sha256.Sum256(contents))
+
+	feed := make(chan reader.Event)
+	go func() { feed <- reader.Event{Op: reader.SubscriberLoadComplete} }()
+	client := tasks.NewClient(writer.NewClient(dir), feed, tasks.WithInitialLoadWaiter(100*time.Millisecond))
+
+	// An event for the old version that was already in flight doesn't satisfy the wait, only the written version does
+	go func() {
+		feed <- reader.Event{Op: reader.Change, Key: "path.md", Document: reader.Document{Contents: contents, Checksum: checksum}}
+		time.Sleep(50 * time.Millisecond)
+		written, _ := os.ReadFile(filepath.Join(dir, "path.md"))
+		feed <- reader.Event{Op: reader.Change, Key: "path.md", Document: reader.Document{Contents: written, Checksum: fmt.Sprintf("%x", sha256.Sum256(written))}}
+	}()
+
+	task := tasks.NewTask(tasks.NewIdentifier("path.md", checksum, 1), "Task", tasks.Doing, tasks.WithSource("- [ ] Task"))
+	assert.NoError(t, client.Update(task, tasks.WithWait(time.Second)))
+	all := client.ListTasks(tasks.FetchAllTasks())
+	if assert.Len(t, all, 1) {
+		assert.Equal(t, tasks.Doing, all[0].Status())
+	}
+
+	// The written version is never handled
+	go func() {
+		feed <- reader.Event{Op: reader.Change, Key: "path.md", Document: reader.Document{Contents: contents, Checksum: checksum}}
+	}()
+	task = tasks.NewTaskFromTask(all[0], tasks.WithStatus(tasks.Done))
+	assert.Error(t, client.Update(task, tasks.WithWait(100*time.Millisecond)))
+}
+
+func TestWrite_Cascade(t *testing.T) {
+	lines := []string{"- [ ] Parent every:day", "  - [x] Child one", "  - [ ] Child two", "    - [ ] Grandchild", "      Notes on the grandchild", "- [ ] Sibling"}
+	var written []string
+	client, _ := buildClient(subtaskEvents(),
+		func(doc writer.Document, mutations ...writer.LineMutation) error {
+			written = lines
+			for _, mutation := range mutations {
+				var err error
+				if written, err = mutation("version", written); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+
+	var parent tasks.Task
+	for _, task := range client.ListTasks(tasks.FetchTasksForDocument("nested.md")) {
+		if task.Line() == 1 {
+			parent = task
+		}
+	}
+
+	// The parent recurs so its completed copy is added below its subtasks
+	completed := tasks.NewTaskFromTask(parent, tasks.WithCompleted(*date(2024, 1, 1, 0)), tasks.WithStatus(tasks.Done))
+	assert.NoError(t, client.Update(completed, tasks.WithCascade()))
+	assert.Equal(t, []string{
+		"- [ ] Parent every:day",
+		"  - [x] Child one",
+		"  - [x] Child two completed:2024-01-01",
+		"    - [x] Grandchild completed:2024-01-01",
+		"      Notes on the grandchild",
+		"- [x] Parent every:day completed:2024-01-01",
+		"- [ ] Sibling",
+	}, written)
+}
+
+func TestWrite_CascadeStale(t *testing.T) {
+	lines := []string{"- [ ] Other", "  - [ ] Other child", "- [ ] Parent", "  - [ ] Child"}
+	var written []string
+	client, feed := buildClient([]reader.Event{
+		{Op: reader.Load, Key: "path.md", Document: reader.Document{Contents: []byte("- [ ] Parent\n  - [ ] Child\n"), Checksum: "version"}},
+		{Op: reader.SubscriberLoadComplete},
+	}, staleWriter(t, lines, &written))
+	parent := client.ListTasks(tasks.FetchTasksForDocument("path.md"), tasks.WithFilters(func(t tasks.Task) bool { return t.Name() == "Parent" }))[0]
+
+	// Lines are added above the parent and the cache is updated before the task is written
+	feed <- reader.Event{Op: reader.Change, Key: "path.md", Document: reader.Document{Contents: []byte(strings.Join(lines, "\n") + "\n"), Checksum: "next"}}
+	assert.Eventually(t, func() bool { return len(client.ListTasks(tasks.FetchTasksForDocument("path.md"))) == 4 }, time.Second, 10*time.Millisecond)
+
+	// The subtasks closed are the ones the task had when it was read, not whatever is at their old lines now
+	completed := tasks.NewTaskFromTask(parent, tasks.WithCompleted(*date(2024, 1, 1, 0)), tasks.WithStatus(tasks.Done))
+	assert.NoError(t, client.Update(completed, tasks.WithCascade()))
+	assert.Equal(t, []string{"- [ ] Other", "  - [ ] Other child", "- [x] Parent completed:2024-01-01", "  - [x] Child completed:2024-01-01"}, written)
+}
+
+func TestWrite_DeleteWithNotesAndSubtasks(t *testing.T) {
+	lines := []string{"- [ ] Parent every:day", "  - [x] Child one", "  - [ ] Child two", "    - [ ] Grandchild", "      Notes on the grandchild", "- [ ] Sibling"}
+	var written []string
+	client, _ := buildClient(subtaskEvents(),
+		func(doc writer.Document, mutations ...writer.LineMutation) error {
+			written = lines
+			for _, mutation := range mutations {
+				var err error
+				if written, err = mutation("version", written); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+
+	for _, task := range client.ListTasks(tasks.FetchTasksForDocument("nested.md")) {
+		if task.Name() == "Child two" {
+			assert.NoError(t, client.Delete(task))
+		}
+	}
+	assert.Equal(t, []string{"- [ ] Parent every:day", "  - [x] Child one", "- [ ] Sibling"}, written)
+}
+
+// The document has changed since it was read, lines added to or removed from a task's block mustn't be deleted or
+// have the completed copy of a recurring task inserted into them
+func TestWrite_StaleBlock(t *testing.T) {
+	tests := []struct {
+		name   string
+		lines  []string
+		task   string
+		write  func(*tasks.Client, tasks.Task) error
+		want   []string
+		reject bool
+		// Whether the cache has been updated with the changed document before the task is written
+		changed bool
+	}{
+		{
+			name:  "Delete a block that has moved",
+			lines: []string{"New line", "- [ ] Parent every:day", "  - [x] Child one", "  - [ ] Child two", "    - [ ] Grandchild", "      Notes on the grandchild", "- [ ] Sibling"},
+			task:  "Child two",
+			write: func(c *tasks.Client, t tasks.Task) error { return c.Delete(t) },
+			want:  []string{"New line", "- [ ] Parent every:day", "  - [x] Child one", "- [ ] Sibling"},
+		},
+		{
+			name:    "Delete a block that has moved after the cache was updated",
+			lines:   []string{"New line", "- [ ] Parent every:day", "  - [x] Child one", "  - [ ] Child two", "    - [ ] Grandchild", "      Notes on the grandchild", "- [ ] Sibling"},
+			task:    "Child two",
+			write:   func(c *tasks.Client, t tasks.Task) error { return c.Delete(t) },
+			want:    []string{"New line", "- [ ] Parent every:day", "  - [x] Child one", "- [ ] Sibling"},
+			changed: true,
+		},
+		{
+			name:  "Complete a recurring task that has moved after the cache was updated",
+			lines: []string{"New line", "- [ ] Parent every:day", "  - [x] Child one", "  - [ ] Child two", "    - [ ] Grandchild", "      Notes on the grandchild", "- [ ] Sibling"},
+			task:  "Parent",
+			write: func(c *tasks.Client, t tasks.Task) error {
+				return c.Update(tasks.NewTaskFromTask(t, tasks.WithCompleted(*date(2024, 1, 1, 0)), tasks.WithStatus(tasks.Done)))
+			},
+			want:    []string{"New line", "- [ ] Parent every:day", "  - [x] Child one", "  - [ ] Child two", "    - [ ] Grandchild", "      Notes on the grandchild", "- [x] Parent every:day completed:2024-01-01", "- [ ] Sibling"},
+			changed: true,
+		},
+		{
+			name:   "Delete a block with a new note",
+			lines:  []string{"- [ ] Parent every:day", "  - [x] Child one", "  - [ ] Child two", "    - [ ] Grandchild", "      Notes on the grandchild", "      More notes", "- [ ] Sibling"},
+			task:   "Child two",
+			write:  func(c *tasks.Client, t tasks.Task) error { return c.Delete(t) },
+			reject: true,
+		},
+		{
+			name:   "Delete a block with a removed subtask",
+			lines:  []string{"- [ ] Parent every:day", "  - [x] Child one", "  - [ ] Child two", "- [ ] Sibling"},
+			task:   "Child two",
+			write:  func(c *tasks.Client, t tasks.Task) error { return c.Delete(t) },
+			reject: true,
+		},
+		{
+			name:  "Complete a recurring task with a new subtask",
+			lines: []string{"- [ ] Parent every:day", "  - [x] Child one", "  - [ ] New child", "  - [ ] Child two", "    - [ ] Grandchild", "      Notes on the grandchild", "- [ ] Sibling"},
+			task:  "Parent",
+			write: func(c *tasks.Client, t tasks.Task) error {
+				return c.Update(tasks.NewTaskFromTask(t, tasks.WithStatus(tasks.Done)))
+			},
+			reject: true,
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var written []string
+			client, feed := buildClient(subtaskEvents(), staleWriter(t, test.lines, &written))
+
+			for _, task := range client.ListTasks(tasks.FetchTasksForDocument("nested.md")) {
+				if task.Name() != test.task {
+					continue
+				}
+				if test.changed {
+					feed <- reader.Event{Op: reader.Change, Key: "nested.md", Document: reader.Document{Contents: []byte(strings.Join(test.lines, "\n") + "\n"), Checksum: "next"}}
+					assert.Eventually(t, func() bool { return client.ListTasks(tasks.FetchTasksForDocument("nested.md"))[0].Version() == "next" }, time.Second, 10*time.Millisecond)
+				}
+				err := test.write(client, task)
+				if test.reject {
+					var conflict *writer.ConflictError
+					assert.True(t, errors.As(err, &conflict), "expected a conflict error got %v", err)
+					return
+				}
+				assert.NoError(t, err)
+				assert.Equal(t, test.want, written)
+			}
+		})
+	}
+}
+
+func TestWrite_CreateWithNotes(t *testing.T) {
+	var written []string
+	client, _ := buildClient([]reader.Event{{Op: reader.SubscriberLoadComplete}},
+		func(doc writer.Document, mutations ...writer.LineMutation) error {
+			written = []string{"line 1"}
+			for _, mutation := range mutations {
+				var err error
+				if written, err = mutation("", written); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+
+	assert.NoError(t, client.Create("path", writer.AT_END, "Plan the trip", tasks.Todo, tasks.WithNotes("  Ask Sam which dates work", "", "  - [budget](./budget.md)")))
+	assert.Equal(t, []string{"line 1", "- [ ] Plan the trip", "  Ask Sam which dates work", "", "  - [budget](./budget.md)"}, written)
+
+	// Notes that aren't indented under the task wouldn't be read back as its notes
+	assert.Error(t, client.Create("path", writer.AT_END, "Plan the trip", tasks.Todo, tasks.WithNotes("Ask Sam")))
+}
+
+func TestWrite_GeneratedIDs(t *testing.T) {
+	var written []string
+	client := tasks.NewClient(&test.MockDocumentContentUpdater{Validators: []test.ContentUpdateValidator{
+		func(doc writer.Document, mutations ...writer.LineMutation) error {
+			for _, mutation := range mutations {
+				written, _ = mutation("", written)
+			}
+			return nil
+		},
+		func(doc writer.Document, mutations ...writer.LineMutation) error {
+			for _, mutation := range mutations {
+				written, _ = mutation("", written)
+			}
+			return nil
+		},
+	}}, make(chan reader.Event), tasks.WithGeneratedIDs())
+
+	assert.NoError(t, client.Create("path", writer.AT_END, "Generated", tasks.Todo))
+	assert.NoError(t, client.Create("path", writer.AT_END, "Explicit", tasks.Todo, tasks.WithID("mine")))
+	assert.Len(t, written, 2)
+	assert.Regexp(t, `^- \[ \] Generated id:[a-z0-9]{6}$`, written[0])
+	assert.Equal(t, "- [ ] Explicit id:mine", written[1])
+}
+
+func TestWrite_NormalisesRelativeDates(t *testing.T) {
+	var written []string
+	client, _ := buildClient(dailyEvents(),
+		func(doc writer.Document, mutations ...writer.LineMutation) error {
+			written = []string{"- [ ] Call the bank due:tomorrow", "- [ ] Plan the week s:next mon"}
+			for _, mutation := range mutations {
+				var err error
+				if written, err = mutation("version", written); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+
+	for _, task := range client.ListTasks(tasks.FetchAllTasks()) {
+		if task.Line() == 1 {
+			assert.NoError(t, client.Update(tasks.NewTaskFromTask(task, tasks.WithStatus(tasks.Doing))))
+		}
+	}
+	assert.Equal(t, []string{"- [/] Call the bank due:2024-11-07", "- [ ] Plan the week s:next mon"}, written)
+}
+
+func TestWrite_RecurringRollsForward(t *testing.T) {
+	var calls int
+	var written []string
+	client, _ := buildClient([]reader.Event{{Op: reader.SubscriberLoadComplete}},
+		func(doc writer.Document, mutations ...writer.LineMutation) error {
+			calls++
+			written = []string{"- [ ] Review every:week due:2024-01-01", "Text"}
+			for _, mutation := range mutations {
+				var err error
+				if written, err = mutation("version", written); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+
+	every, err := tasks.NewEvery("week")
+	assert.NoError(t, err)
+	task := tasks.NewTask(tasks.NewIdentifier("path", "version", 1), "Review", tasks.Todo, tasks.WithEvery(every), tasks.WithDue(*date(2024, 1, 1, 0)), tasks.WithSource("- [ ] Review every:week due:2024-01-01"))
+	assert.NoError(t, client.Update(tasks.NewTaskFromTask(task, tasks.WithCompleted(*date(2024, 1, 2, 0)), tasks.WithStatus(tasks.Done))))
+
+	assert.Equal(t, 1, calls)
+	assert.Equal(t, []string{
+		"- [ ] Review every:week due:2024-01-08",
+		"- [x] Review every:week due:2024-01-01 completed:2024-01-02",
+		"Text",
+	}, written)
+}
+
+func TestWrite_RecurrenceFinished(t *testing.T) {
+	var written []string
+	client, _ := buildClient([]reader.Event{{Op: reader.SubscriberLoadComplete}},
+		func(doc writer.Document, mutations ...writer.LineMutation) error {
+			written = []string{"- [ ] Review every:week for 1 time due:2024-01-01", "Text"}
+			for _, mutation := range mutations {
+				var err error
+				if written, err = mutation("version", written); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	)
+
+	every, err := tasks.NewEvery("week for 1 time")
+	assert.NoError(t, err)
+	task := tasks.NewTask(tasks.NewIdentifier("path", "version", 1), "Review", tasks.Todo, tasks.WithEvery(every), tasks.WithDue(*date(2024, 1, 1, 0)), tasks.WithSource("- [ ] Review every:week for 1 time due:2024-01-01"))
+	assert.NoError(t, client.Update(tasks.NewTaskFromTask(task, tasks.WithCompleted(*date(2024, 1, 2, 0)), tasks.WithStatus(tasks.Done))))
+
+	assert.Equal(t, []string{
+		"- [x] Review every:week for 1 time due:2024-01-01 completed:2024-01-02",
+		"Text",
+	}, written)
+}
+
+func TestWrite_TimeTracking(t *testing.T) {
+	var written []string
+	write := func(doc writer.Document, mutations ...writer.LineMutation) error {
+		written = []string{"- [ ] Write report estimate:1h"}
+		for _, mutation := range mutations {
+			var err error
+			if written, err = mutation("version", written); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	client, _ := buildClient([]reader.Event{{Op: reader.SubscriberLoadComplete}}, write, write, write)
+
+	task := tasks.NewTask(tasks.NewIdentifier("path", "version", 1), "Write report", tasks.Todo, tasks.WithEstimate(time.Hour), tasks.WithSource("- [ ] Write report estimate:1h"))
+	assert.NoError(t, client.Update(tasks.NewTaskFromTask(task, tasks.WithStatus(tasks.Doing)), tasks.WithTimeTracking()))
+	assert.Regexp(t, `^- \[/\] Write report estimate:1h started:\d{4}-\d{2}-\d{2}T\d{2}:\d{2}$`, written[0])
+
+	// Without tracking the status changes on its own
+	assert.NoError(t, client.Update(tasks.NewTaskFromTask(task, tasks.WithStatus(tasks.Doing))))
+	assert.Equal(t, "- [/] Write report estimate:1h", written[0])
+
+	started := tasks.NewTaskFromTask(task, tasks.WithStatus(tasks.Doing), tasks.WithStarted(time.Now().Add(-3*time.Hour)))
+	assert.NoError(t, client.Update(tasks.NewTaskFromTask(started, tasks.WithStatus(tasks.Done)), tasks.WithTimeTracking()))
+	assert.Regexp(t, `^- \[x\] Write report estimate:1h spent:(2h59m|3h|3h1m) completed:\S+$`, written[0])
 }